@@ -0,0 +1,404 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Archive formats recognised by --diff
+const (
+	archiveZip   = "zip"
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz"
+	archiveTarXz = "tar.xz"
+	archiveTarZs = "tar.zst"
+)
+
+var (
+	magicZip   = []byte("PK\x03\x04")
+	magicZipE  = []byte("PK\x05\x06") // empty zip: end of central directory only
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicXz    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	magicUstar = []byte("ustar")
+)
+
+// ArchiveEntry is one member of an archive
+type ArchiveEntry struct {
+	Name     string
+	Type     string // "file", "directory", "symlink", "hardlink" or "other"
+	Size     int64
+	Mode     os.FileMode
+	ModTime  time.Time
+	Owner    string // tar only
+	Linkname string
+	SHA256   string // regular files only
+}
+
+// ArchiveChange lists what changed in an entry present in both archives
+type ArchiveChange struct {
+	Name    string
+	Changes []string
+}
+
+// ArchiveDiff compares the entries of two archives
+type ArchiveDiff struct {
+	FormatA, FormatB string
+	Added            []ArchiveEntry
+	Removed          []ArchiveEntry
+	Changed          []ArchiveChange
+	Unchanged        int
+}
+
+// Differs reports whether any entry differs
+func (d *ArchiveDiff) Differs() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Changed) > 0
+}
+
+// detectArchive returns the archive format of a file, or "" if it is not one.
+// Compressed files only count when they hold a tar stream.
+func detectArchive(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, magicZip), bytes.HasPrefix(head, magicZipE):
+		return archiveZip
+	case isTarHeader(head):
+		return archiveTar
+	}
+
+	format := ""
+	switch {
+	case bytes.HasPrefix(head, magicGzip):
+		format = archiveTarGz
+	case bytes.HasPrefix(head, magicXz):
+		format = archiveTarXz
+	case bytes.HasPrefix(head, magicZstd):
+		format = archiveTarZs
+	default:
+		return ""
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	r, closer, err := decompressor(format, f)
+	if err != nil {
+		return ""
+	}
+	defer closer()
+	inner := make([]byte, 512)
+	n, _ = io.ReadFull(r, inner)
+	if !isTarHeader(inner[:n]) {
+		return ""
+	}
+	return format
+}
+
+// isTarHeader checks for the ustar magic in a tar header block
+func isTarHeader(block []byte) bool {
+	return len(block) >= 262 && bytes.Equal(block[257:262], magicUstar)
+}
+
+// decompressor wraps r for a compressed tar format
+func decompressor(format string, r io.Reader) (io.Reader, func(), error) {
+	switch format {
+	case archiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gz, func() { _ = gz.Close() }, nil
+	case archiveTarXz:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return xr, func() {}, nil
+	case archiveTarZs:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	}
+	return r, func() {}, nil
+}
+
+// maxZipLinkTarget bounds the body of a zip symlink entry, which holds the target path
+const maxZipLinkTarget = 4096
+
+// ReadArchive lists the entries of an archive, hashing file contents as they
+// stream past; nothing is extracted to disk. A name that appears more than once
+// is keyed by its occurrence, e.g. "a.txt (#2)".
+func ReadArchive(path, format string) (map[string]ArchiveEntry, error) {
+	if format == archiveZip {
+		return readZip(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	r, closer, err := decompressor(format, bufio.NewReader(&contextReader{ctx: HashContext, r: f}))
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	entries := make(map[string]ArchiveEntry)
+	seen := make(map[string]int)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		e := ArchiveEntry{
+			Name:     strings.TrimPrefix(hdr.Name, "./"),
+			Size:     hdr.Size,
+			Mode:     hdr.FileInfo().Mode(),
+			ModTime:  hdr.ModTime,
+			Owner:    tarOwner(hdr),
+			Linkname: hdr.Linkname,
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			e.Type = "file"
+			if e.SHA256, err = sha256Reader(tr); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", path, hdr.Name, err)
+			}
+		case tar.TypeDir:
+			e.Type = "directory"
+		case tar.TypeSymlink:
+			e.Type = "symlink"
+		case tar.TypeLink:
+			e.Type = "hardlink"
+		default:
+			e.Type = "other"
+		}
+		e.Name = strings.TrimSuffix(e.Name, "/")
+		if e.Name != "" && e.Name != "." {
+			addArchiveEntry(entries, seen, e)
+		}
+	}
+	return entries, nil
+}
+
+// readZip lists the entries of a zip (or jar) file
+func readZip(path string) (map[string]ArchiveEntry, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = zr.Close() }()
+
+	entries := make(map[string]ArchiveEntry)
+	seen := make(map[string]int)
+	for _, zf := range zr.File {
+		if err := HashContext.Err(); err != nil {
+			return nil, err
+		}
+		e := ArchiveEntry{
+			Name:    strings.TrimSuffix(zf.Name, "/"),
+			Size:    int64(zf.UncompressedSize64),
+			Mode:    zf.Mode(),
+			ModTime: zf.Modified,
+		}
+		switch {
+		case zf.Mode().IsDir():
+			e.Type = "directory"
+		case zf.Mode()&os.ModeSymlink != 0:
+			// The entry's body is the link target
+			e.Type = "symlink"
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", path, zf.Name, err)
+			}
+			target, err := io.ReadAll(io.LimitReader(rc, maxZipLinkTarget))
+			_ = rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", path, zf.Name, err)
+			}
+			e.Linkname = string(target)
+		default:
+			e.Type = "file"
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", path, zf.Name, err)
+			}
+			e.SHA256, err = sha256Reader(rc)
+			_ = rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", path, zf.Name, err)
+			}
+		}
+		if e.Name != "" {
+			addArchiveEntry(entries, seen, e)
+		}
+	}
+	return entries, nil
+}
+
+// addArchiveEntry records an entry under its name, numbering repeats of a name
+// (tar appends updated copies) so that no entry replaces another
+func addArchiveEntry(entries map[string]ArchiveEntry, seen map[string]int, e ArchiveEntry) {
+	seen[e.Name]++
+	if n := seen[e.Name]; n > 1 {
+		e.Name = fmt.Sprintf("%s (#%d)", e.Name, n)
+	}
+	entries[e.Name] = e
+}
+
+// tarOwner formats the owner recorded in a tar header
+func tarOwner(hdr *tar.Header) string {
+	user, group := hdr.Uname, hdr.Gname
+	if user == "" {
+		user = fmt.Sprintf("%d", hdr.Uid)
+	}
+	if group == "" {
+		group = fmt.Sprintf("%d", hdr.Gid)
+	}
+	return user + ":" + group
+}
+
+// sha256Reader hashes everything r yields
+func sha256Reader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, &contextReader{ctx: HashContext, r: r}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CompareArchives compares the entries of two archives. Fields in ignore
+// ("size", "mode", "mtime", "owner") are not compared.
+func CompareArchives(pathA, formatA, pathB, formatB string, ignore []string) (*ArchiveDiff, error) {
+	a, err := ReadArchive(pathA, formatA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ReadArchive(pathB, formatB)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &ArchiveDiff{FormatA: formatA, FormatB: formatB}
+	for _, name := range unionKeys(a, b) {
+		ea, inA := a[name]
+		eb, inB := b[name]
+		switch {
+		case !inA:
+			d.Added = append(d.Added, eb)
+		case !inB:
+			d.Removed = append(d.Removed, ea)
+		default:
+			if changes := archiveEntryChanges(ea, eb, ignore); len(changes) > 0 {
+				d.Changed = append(d.Changed, ArchiveChange{Name: name, Changes: changes})
+			} else {
+				d.Unchanged++
+			}
+		}
+	}
+	return d, nil
+}
+
+// archiveEntryChanges describes how an entry differs between two archives
+func archiveEntryChanges(a, b ArchiveEntry, ignore []string) []string {
+	var changes []string
+	if a.Type != b.Type {
+		return []string{fmt.Sprintf("type %s → %s", a.Type, b.Type)}
+	}
+	if a.SHA256 != b.SHA256 {
+		changes = append(changes, "content")
+	}
+	if a.Size != b.Size && !ignoresField(ignore, "size") {
+		changes = append(changes, fmt.Sprintf("size %d → %d", a.Size, b.Size))
+	}
+	if a.Mode != b.Mode && !ignoresField(ignore, "mode") {
+		changes = append(changes, fmt.Sprintf("mode %s → %s", a.Mode, b.Mode))
+	}
+	if !a.ModTime.Equal(b.ModTime) && !ignoresField(ignore, "mtime") {
+		changes = append(changes, fmt.Sprintf("mtime %s → %s", a.ModTime.Format("2006-01-02 15:04:05"), b.ModTime.Format("2006-01-02 15:04:05")))
+	}
+	if a.Owner != b.Owner && !ignoresField(ignore, "owner") {
+		changes = append(changes, fmt.Sprintf("owner %s → %s", a.Owner, b.Owner))
+	}
+	if a.Linkname != b.Linkname {
+		changes = append(changes, fmt.Sprintf("link %s → %s", a.Linkname, b.Linkname))
+	}
+	return changes
+}
+
+// FormatArchiveDiff renders an archive comparison with colors
+func FormatArchiveDiff(d *ArchiveDiff, labelFn, treeFn, matchFn, diffFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+
+	format := d.FormatA
+	if d.FormatA != d.FormatB {
+		format = d.FormatA + " → " + d.FormatB
+	}
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Format    :"), valueFn(format))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("╰─"), treeFn("Entries   :"),
+		valueFn(fmt.Sprintf("%d unchanged, %d changed, %d added, %d removed", d.Unchanged, len(d.Changed), len(d.Added), len(d.Removed))))
+
+	entries := func(title, sign string, list []ArchiveEntry, fn func(a ...interface{}) string) {
+		if len(list) == 0 {
+			return
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+		fmt.Fprintf(&sb, "\n%s\n", labelFn(title))
+		for _, e := range list {
+			detail := e.Type
+			if e.Type == "file" {
+				detail = fmt.Sprintf("%d bytes", e.Size)
+			}
+			fmt.Fprintf(&sb, "  %s %s %s\n", fn(sign), valueFn(e.Name), treeFn("("+detail+")"))
+		}
+	}
+	entries("Removed entries:", "-", d.Removed, diffFn)
+	entries("Added entries:", "+", d.Added, matchFn)
+
+	if len(d.Changed) > 0 {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn("Changed entries:"))
+		for _, c := range d.Changed {
+			fmt.Fprintf(&sb, "  %s %s\n", diffFn("✗"), valueFn(c.Name))
+			for i, change := range c.Changes {
+				branch := "├─"
+				if i == len(c.Changes)-1 {
+					branch = "╰─"
+				}
+				fmt.Fprintf(&sb, "    %s %s\n", treeFn(branch), valueFn(change))
+			}
+		}
+	}
+	return sb.String()
+}
@@ -114,6 +114,14 @@ func analyzeELF(path string, info *BinaryInfo) error {
 		info.IsStripped = true
 	}
 
+	// ldd would ask the host's loader; inside a root, resolve like ld.so does
+	if sysroot != "" {
+		if libs := rootLinkedLibraries(path); len(libs) > 0 {
+			info.LinkedLibraries = libs
+		}
+		return nil
+	}
+
 	// Get dynamic libraries using ldd (if available)
 	cmd := exec.Command("ldd", path)
 	output, err := cmd.Output()
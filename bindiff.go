@@ -0,0 +1,379 @@
+package main
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxListedSymbols limits how many added/removed names are printed per list
+const maxListedSymbols = 20
+
+// BinaryStructure is the parsed layout of an ELF or Mach-O file
+type BinaryStructure struct {
+	Format    string // "ELF" or "Mach-O"
+	Arch      string
+	Type      string
+	Entry     uint64
+	Sections  map[string]uint64 // name → size
+	Exports   map[string]bool   // "name" or "name@VERSION"
+	Imports   map[string]bool
+	Versions  map[string]bool // symbol version definitions (ELF)
+	Needed    []string        // DT_NEEDED / LC_LOAD_DYLIB
+	SONAME    string          // DT_SONAME / LC_ID_DYLIB
+	RPaths    []string        // DT_RPATH, DT_RUNPATH / LC_RPATH
+	Hardening map[string]string
+}
+
+// ReadBinaryStructure parses headers, sections, dynamic symbols and dependencies
+func ReadBinaryStructure(path string) (*BinaryStructure, error) {
+	if f, err := elf.Open(path); err == nil {
+		defer func() { _ = f.Close() }()
+		return readELFStructure(f), nil
+	}
+	if f, err := macho.Open(path); err == nil {
+		defer func() { _ = f.Close() }()
+		return readMachOStructure(f), nil
+	}
+	return nil, fmt.Errorf("%s is not an ELF or Mach-O file", path)
+}
+
+// newBinaryStructure returns a structure with its maps initialized
+func newBinaryStructure(format string) *BinaryStructure {
+	return &BinaryStructure{
+		Format:    format,
+		Sections:  make(map[string]uint64),
+		Exports:   make(map[string]bool),
+		Imports:   make(map[string]bool),
+		Versions:  make(map[string]bool),
+		Hardening: make(map[string]string),
+	}
+}
+
+// readELFStructure extracts the comparable parts of an ELF file
+func readELFStructure(f *elf.File) *BinaryStructure {
+	bs := newBinaryStructure("ELF")
+	bs.Arch = f.Machine.String()
+	bs.Type = f.Type.String()
+	bs.Entry = f.Entry
+
+	for _, s := range f.Sections {
+		if s.Name != "" {
+			bs.Sections[s.Name] = s.Size
+		}
+	}
+
+	syms, _ := f.DynamicSymbols()
+	for _, s := range syms {
+		name := s.Name
+		if s.Version != "" {
+			name += "@" + s.Version
+		}
+		bind := elf.ST_BIND(s.Info)
+		if s.Section == elf.SHN_UNDEF {
+			bs.Imports[name] = true
+		} else if bind == elf.STB_GLOBAL || bind == elf.STB_WEAK {
+			bs.Exports[name] = true
+		}
+	}
+
+	versions, _ := f.DynamicVersions()
+	for _, v := range versions {
+		if v.Flags&elf.VER_FLG_BASE == 0 {
+			bs.Versions[v.Name] = true
+		}
+	}
+
+	bs.Needed, _ = f.ImportedLibraries()
+	if soname, _ := f.DynString(elf.DT_SONAME); len(soname) > 0 {
+		bs.SONAME = soname[0]
+	}
+	rpath, _ := f.DynString(elf.DT_RPATH)
+	runpath, _ := f.DynString(elf.DT_RUNPATH)
+	for _, p := range append(rpath, runpath...) {
+		bs.RPaths = append(bs.RPaths, strings.Split(p, ":")...)
+	}
+
+	bs.Hardening = elfHardening(f, bs.Imports)
+	return bs
+}
+
+// elfHardening reports PIE, NX, RELRO, stack canary and FORTIFY_SOURCE status
+func elfHardening(f *elf.File, imports map[string]bool) map[string]string {
+	h := map[string]string{
+		"PIE":      "no",
+		"NX":       "yes",
+		"RELRO":    "no",
+		"Canary":   "no",
+		"Fortify":  "no",
+		"BIND_NOW": "no",
+	}
+
+	hasInterp := false
+	for _, p := range f.Progs {
+		switch p.Type {
+		case elf.PT_INTERP:
+			hasInterp = true
+		case elf.PT_GNU_STACK:
+			if p.Flags&elf.PF_X != 0 {
+				h["NX"] = "no"
+			}
+		case elf.PT_GNU_RELRO:
+			h["RELRO"] = "partial"
+		}
+	}
+	if f.Type == elf.ET_DYN && hasInterp {
+		h["PIE"] = "yes"
+	}
+	if flags1, _ := f.DynValue(elf.DT_FLAGS_1); len(flags1) > 0 && flags1[0]&uint64(elf.DF_1_PIE) != 0 {
+		h["PIE"] = "yes"
+	}
+
+	bindNow := false
+	if v, _ := f.DynValue(elf.DT_BIND_NOW); len(v) > 0 {
+		bindNow = true
+	}
+	if flags, _ := f.DynValue(elf.DT_FLAGS); len(flags) > 0 && flags[0]&uint64(elf.DF_BIND_NOW) != 0 {
+		bindNow = true
+	}
+	if flags1, _ := f.DynValue(elf.DT_FLAGS_1); len(flags1) > 0 && flags1[0]&uint64(elf.DF_1_NOW) != 0 {
+		bindNow = true
+	}
+	if bindNow {
+		h["BIND_NOW"] = "yes"
+		if h["RELRO"] == "partial" {
+			h["RELRO"] = "full"
+		}
+	}
+
+	for name := range imports {
+		base := strings.SplitN(name, "@", 2)[0]
+		if base == "__stack_chk_fail" {
+			h["Canary"] = "yes"
+		}
+		if strings.HasPrefix(base, "__") && strings.HasSuffix(base, "_chk") && base != "__stack_chk_fail" {
+			h["Fortify"] = "yes"
+		}
+	}
+	return h
+}
+
+// Mach-O load commands not decoded by debug/macho
+const (
+	lcIDDylib = 0xd
+)
+
+// readMachOStructure extracts the comparable parts of a Mach-O file
+func readMachOStructure(f *macho.File) *BinaryStructure {
+	bs := newBinaryStructure("Mach-O")
+	bs.Arch = f.Cpu.String()
+	bs.Type = f.Type.String()
+
+	for _, s := range f.Sections {
+		bs.Sections[s.Seg+","+s.Name] = s.Size
+	}
+
+	if f.Symtab != nil {
+		for _, s := range f.Symtab.Syms {
+			const nExt, nType, nUndf = 0x01, 0x0e, 0x0
+			if s.Type&nExt == 0 || s.Type&0xe0 != 0 {
+				continue // local or debugging symbol
+			}
+			if s.Type&nType == nUndf {
+				bs.Imports[s.Name] = true
+			} else {
+				bs.Exports[s.Name] = true
+			}
+		}
+	}
+
+	bs.Needed, _ = f.ImportedLibraries()
+	for _, load := range f.Loads {
+		switch l := load.(type) {
+		case *macho.Rpath:
+			bs.RPaths = append(bs.RPaths, l.Path)
+		case macho.LoadBytes:
+			raw := []byte(l)
+			if len(raw) >= 24 && f.ByteOrder.Uint32(raw) == lcIDDylib {
+				nameOff := f.ByteOrder.Uint32(raw[8:])
+				if int(nameOff) < len(raw) {
+					bs.SONAME = strings.TrimRight(string(raw[nameOff:]), "\x00")
+				}
+			}
+		}
+	}
+
+	pie := "no"
+	if f.Flags&macho.FlagPIE != 0 {
+		pie = "yes"
+	}
+	nx := "yes"
+	if f.Flags&macho.FlagAllowStackExecution != 0 {
+		nx = "no"
+	}
+	canary := "no"
+	if bs.Imports["___stack_chk_fail"] {
+		canary = "yes"
+	}
+	bs.Hardening = map[string]string{"PIE": pie, "NX": nx, "Canary": canary}
+	return bs
+}
+
+// FormatBinaryStructureDiff compares two binary structures and flags likely ABI breaks
+func FormatBinaryStructureDiff(a, b *BinaryStructure, labelFn, treeFn, matchFn, diffFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+
+	field := func(name, v1, v2 string) {
+		if v1 == v2 {
+			fmt.Fprintf(&sb, "  %s %s %s\n", matchFn("✓"), treeFn(name+":"), valueFn(v1))
+		} else {
+			fmt.Fprintf(&sb, "  %s %s %s → %s\n", diffFn("✗"), treeFn(name+":"), valueFn(v1), valueFn(v2))
+		}
+	}
+	orNone := func(s string) string {
+		if s == "" {
+			return "(none)"
+		}
+		return s
+	}
+
+	sb.WriteString(labelFn("Headers:\n"))
+	field("Format", a.Format, b.Format)
+	field("Arch  ", a.Arch, b.Arch)
+	field("Type  ", a.Type, b.Type)
+	field("Entry ", fmt.Sprintf("0x%x", a.Entry), fmt.Sprintf("0x%x", b.Entry))
+	field("SONAME", orNone(a.SONAME), orNone(b.SONAME))
+	field("RPATH ", orNone(strings.Join(a.RPaths, ":")), orNone(strings.Join(b.RPaths, ":")))
+
+	// Sections: only changes are listed
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Sections:"))
+	names := unionKeys(a.Sections, b.Sections)
+	unchanged := 0
+	for _, name := range names {
+		s1, ok1 := a.Sections[name]
+		s2, ok2 := b.Sections[name]
+		switch {
+		case !ok1:
+			fmt.Fprintf(&sb, "  %s %s %s\n", matchFn("+"), valueFn(name), valueFn(fmt.Sprintf("(%d bytes)", s2)))
+		case !ok2:
+			fmt.Fprintf(&sb, "  %s %s %s\n", diffFn("-"), valueFn(name), valueFn(fmt.Sprintf("(%d bytes)", s1)))
+		case s1 != s2:
+			fmt.Fprintf(&sb, "  %s %s %s\n", diffFn("✗"), valueFn(name),
+				valueFn(fmt.Sprintf("%d → %d bytes (%+d)", s1, s2, int64(s2)-int64(s1))))
+		default:
+			unchanged++
+		}
+	}
+	fmt.Fprintf(&sb, "  %s %s\n", matchFn("✓"), valueFn(fmt.Sprintf("%d section(s) unchanged", unchanged)))
+
+	addedExports, removedExports := setDiff(a.Exports, b.Exports)
+	addedImports, removedImports := setDiff(a.Imports, b.Imports)
+	addedVersions, removedVersions := setDiff(a.Versions, b.Versions)
+	addedNeeded, removedNeeded := setDiff(toSet(a.Needed), toSet(b.Needed))
+
+	writeChanges(&sb, "Exported symbols:", len(a.Exports), addedExports, removedExports, labelFn, matchFn, diffFn, valueFn)
+	writeChanges(&sb, "Imported symbols:", len(a.Imports), addedImports, removedImports, labelFn, matchFn, diffFn, valueFn)
+	if len(a.Versions) > 0 || len(b.Versions) > 0 {
+		writeChanges(&sb, "Symbol versions:", len(a.Versions), addedVersions, removedVersions, labelFn, matchFn, diffFn, valueFn)
+	}
+	writeChanges(&sb, "Dependencies:", len(a.Needed), addedNeeded, removedNeeded, labelFn, matchFn, diffFn, valueFn)
+
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Hardening:"))
+	for _, name := range unionKeys(a.Hardening, b.Hardening) {
+		field(fmt.Sprintf("%-8s", name), orNone(a.Hardening[name]), orNone(b.Hardening[name]))
+	}
+
+	warnings := abiBreaks(a, b)
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("ABI:"))
+	if len(warnings) == 0 {
+		fmt.Fprintf(&sb, "  %s %s\n", matchFn("✓"), valueFn("No likely ABI breaks"))
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(&sb, "  %s %s\n", diffFn("⚠"), diffFn("Possible ABI break: "+w))
+	}
+
+	return sb.String()
+}
+
+// abiBreaks lists the changes from a to b likely to break consumers of the library
+func abiBreaks(a, b *BinaryStructure) []string {
+	var breaks []string
+	if _, removed := setDiff(a.Exports, b.Exports); len(removed) > 0 {
+		breaks = append(breaks, fmt.Sprintf("%d exported symbol(s) removed", len(removed)))
+	}
+	if _, removed := setDiff(a.Versions, b.Versions); len(removed) > 0 {
+		breaks = append(breaks, fmt.Sprintf("symbol version(s) removed: %s", strings.Join(removed, ", ")))
+	}
+	if a.SONAME != b.SONAME {
+		breaks = append(breaks, "SONAME changed")
+	}
+	if a.Arch != b.Arch {
+		breaks = append(breaks, "architecture changed")
+	}
+	return breaks
+}
+
+// writeChanges prints added and removed names for one category
+func writeChanges(sb *strings.Builder, title string, total int, added, removed []string, labelFn, matchFn, diffFn, valueFn func(a ...interface{}) string) {
+	fmt.Fprintf(sb, "\n%s\n", labelFn(title))
+	if len(added) == 0 && len(removed) == 0 {
+		fmt.Fprintf(sb, "  %s %s\n", matchFn("✓"), valueFn(fmt.Sprintf("%d unchanged", total)))
+		return
+	}
+	fmt.Fprintf(sb, "  %s\n", valueFn(fmt.Sprintf("+%d -%d", len(added), len(removed))))
+	list := func(names []string, sign string, fn func(a ...interface{}) string) {
+		for i, name := range names {
+			if i == maxListedSymbols {
+				fmt.Fprintf(sb, "  %s %s\n", fn(sign), valueFn(fmt.Sprintf("... and %d more", len(names)-i)))
+				break
+			}
+			fmt.Fprintf(sb, "  %s %s\n", fn(sign), valueFn(name))
+		}
+	}
+	list(removed, "-", diffFn)
+	list(added, "+", matchFn)
+}
+
+// setDiff returns sorted keys only in b (added) and only in a (removed)
+func setDiff(a, b map[string]bool) (added, removed []string) {
+	for k := range b {
+		if !a[k] {
+			added = append(added, k)
+		}
+	}
+	for k := range a {
+		if !b[k] {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// toSet converts a list to a set
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// unionKeys returns the sorted union of two maps' keys
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range []map[string]V{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
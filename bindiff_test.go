@@ -0,0 +1,54 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// libStructure builds an ELF library structure with the given exports and versions
+func libStructure(soname string, exports, versions []string) *BinaryStructure {
+	bs := newBinaryStructure("ELF")
+	bs.Arch, bs.SONAME = "x86-64", soname
+	for _, e := range exports {
+		bs.Exports[e] = true
+	}
+	for _, v := range versions {
+		bs.Versions[v] = true
+	}
+	return bs
+}
+
+func TestABIBreaks(t *testing.T) {
+	old := libStructure("libfoo.so.1", []string{"foo_open", "foo_close"}, []string{"FOO_1.0", "FOO_1.1"})
+
+	// Additions are compatible
+	added := libStructure("libfoo.so.1", []string{"foo_open", "foo_close", "foo_read"}, []string{"FOO_1.0", "FOO_1.1", "FOO_1.2"})
+	if breaks := abiBreaks(old, added); len(breaks) != 0 {
+		t.Errorf("additions reported as breaks: %v", breaks)
+	}
+
+	broken := libStructure("libfoo.so.2", []string{"foo_open"}, []string{"FOO_1.0"})
+	broken.Arch = "aarch64"
+	want := []string{"1 exported symbol(s) removed", "symbol version(s) removed: FOO_1.1", "SONAME changed", "architecture changed"}
+	if breaks := abiBreaks(old, broken); !slices.Equal(breaks, want) {
+		t.Errorf("got %v, want %v", breaks, want)
+	}
+}
+
+func TestDiffSummaryABIBreaks(t *testing.T) {
+	s := &DiffSummary{Path1: "a.so", Path2: "b.so", Kind: "file", Verdict: VerdictDifferent,
+		ABIBreaks: []string{"1 exported symbol(s) removed", "SONAME changed"}}
+
+	brief := FormatDiffBrief(s)
+	if !strings.Contains(brief, "possible ABI break: 1 exported symbol(s) removed; SONAME changed") {
+		t.Errorf("brief output lacks the ABI breaks: %q", brief)
+	}
+	out, err := FormatDiffJSON(s, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"abi_breaks":["1 exported symbol(s) removed","SONAME changed"]`) {
+		t.Errorf("JSON output lacks the ABI breaks: %s", out)
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// FindDuplicatesFunc is a function type for finding duplicate files (paths, JSON output, plan action)
+var FindDuplicatesFunc func([]string, bool, string) (string, error)
+
+var dupesJSON bool
+var dupesPlan string
+
+// dupesCmd finds files with identical content
+var dupesCmd = &cobra.Command{
+	Use:   "dupes PATH...",
+	Short: "Find duplicate files across paths",
+	Long: `Find files with identical content under the given files and directories.
+
+Files are grouped by size, then by a hash of their first 64 KiB, and only then
+by a full SHA256, so most files are never read completely. Files that are
+already hardlinked together are reported but not counted as wasted space.
+
+With --plan, a JSON plan for hardlinking or removing duplicates is printed.
+finfo never modifies files; the plan is for review or for another tool.
+
+Examples:
+  finfo dupes ~/Downloads ~/Documents
+  finfo dupes --json /srv/data
+  finfo dupes --plan hardlink /srv/data > plan.json`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if SetDisableColorsFunc != nil {
+			SetDisableColorsFunc(noColor)
+		}
+		if SetProgressFunc != nil {
+			SetProgressFunc(cmd.Context(), false, 0)
+		}
+		if FindDuplicatesFunc == nil {
+			fmt.Fprintf(os.Stderr, "Error: Duplicate search not available\n")
+			os.Exit(1)
+		}
+
+		output, err := FindDuplicatesFunc(args, dupesJSON, dupesPlan)
+		exitIfCancelled(cmd.Context())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(output)
+	},
+}
+
+func init() {
+	dupesCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	dupesCmd.Flags().BoolVar(&dupesJSON, "json", false, "Output duplicate groups as JSON")
+	dupesCmd.Flags().StringVar(&dupesPlan, "plan", "", "Print a JSON plan to 'hardlink' or 'remove' duplicates (nothing is changed)")
+	rootCmd.AddCommand(dupesCmd)
+}
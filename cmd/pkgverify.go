@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// VerifyPackagesFunc checks installed packages (names, list every file),
+// returning the report and whether any file failed
+var VerifyPackagesFunc func([]string, bool) (string, bool, error)
+
+var pkgVerifyAll bool
+
+// pkgVerifyCmd checks package files against the package manager's checksums
+var pkgVerifyCmd = &cobra.Command{
+	Use:   "pkg-verify PACKAGE...",
+	Short: "Check a package's files against the checksums recorded at install",
+	Long: `Check every file of installed packages against the checksums the package
+manager recorded, like debsums: dpkg md5sums files, the SHA1 checksums in the
+apk installed database and pacman's mtree files.
+
+Modified and missing files are listed with the expected and actual digests.
+Modified conffiles are reported but do not fail the check, since they are
+meant to be edited.
+
+Exits 0 when every file matches, 1 when a file was modified or removed and
+2 on error.
+
+Examples:
+  finfo pkg-verify coreutils
+  finfo pkg-verify --all openssh-client
+  finfo pkg-verify libssl3:amd64
+  finfo pkg-verify --root /srv/image busybox`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if SetDisableColorsFunc != nil {
+			SetDisableColorsFunc(noColor)
+		}
+		if !applyRoot(nil) {
+			os.Exit(2)
+		}
+		if VerifyPackagesFunc == nil {
+			fmt.Fprintf(os.Stderr, "Error: Package verification not available\n")
+			os.Exit(2)
+		}
+
+		output, failed, err := VerifyPackagesFunc(args, pkgVerifyAll)
+		exitIfCancelled(cmd.Context())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		fmt.Print(output)
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	pkgVerifyCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	pkgVerifyCmd.Flags().BoolVar(&pkgVerifyAll, "all", false, "List every file, not only the ones that fail")
+	pkgVerifyCmd.Flags().StringVar(&rootDir, "root", "", "Read the package database and files inside DIR")
+	pkgVerifyCmd.Flags().StringVar(&rootDir, "sysroot", "", "Alias for --root")
+	rootCmd.AddCommand(pkgVerifyCmd)
+}
@@ -4,9 +4,12 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -26,20 +29,100 @@ var SetDisableColorsFunc func(bool)
 // ResolveCommandFunc is a function type for resolving commands in PATH
 var ResolveCommandFunc func(string) (string, error)
 
-// FindLibraryFunc is a function type for finding libraries
-var FindLibraryFunc func(string) ([]string, error)
+// FindLibraryFunc finds libraries by name using a match mode (name, glob, regex or fuzzy)
+var FindLibraryFunc func(name, mode string) ([]string, error)
 
-// CompareFilesFunc is a function type for comparing files
-var CompareFilesFunc func(string, string) (string, error)
+// FormatLibrarySummaryFunc renders one line per library found, instead of full file info
+var FormatLibrarySummaryFunc func(query string, paths []string) string
+
+// FindSymbolProvidersFunc lists the libraries defining each symbol, returning
+// the output and whether every symbol was found
+var FindSymbolProvidersFunc func(symbols []string) (string, bool, error)
+
+// FindReverseDepsFunc lists the files that link against a library, directly or
+// indirectly, returning the output and whether any were found
+var FindReverseDepsFunc func(library string, dirs []string) (string, bool, error)
+
+// FindPkgConfigFunc lists the pkg-config packages matching a library name
+var FindPkgConfigFunc func(string) (string, error)
+
+// CompareFilesFunc compares two or more paths, returning the output (JSON or a
+// one-line verdict when requested) and whether they differ. An error that comes
+// with output means some paths could not be compared.
+var CompareFilesFunc func(paths []string, jsonOut, brief bool) (string, bool, error)
+
+// DiffOptions holds the --diff display options
+type DiffOptions struct {
+	ContextLines int
+	WordDiff     bool
+	MaxTextSize  int64
+
+	IgnoreVolatile bool
+
+	Excludes     []string
+	MetadataOnly bool
+	Detail       bool
+
+	Ignore []string
+	Strict bool
+}
+
+// SetDiffOptionsFunc is a function type for setting --diff options
+var SetDiffOptionsFunc func(DiffOptions)
+
+// Expectations holds the --expect reference values
+type Expectations struct {
+	Digests []string
+	Size    int64 // -1 when not checked
+	Mode    string
+}
+
+// CheckExpectationsFunc checks files against expected values, returning the
+// output and whether any file failed to match
+var CheckExpectationsFunc func(paths []string, exp Expectations, jsonOut, brief bool) (string, bool, error)
 
 // SetCalculateHashesFunc is a function type for setting hash calculation flag
 var SetCalculateHashesFunc func(bool)
 
+// SetFuzzyHashFunc is a function type for enabling the ssdeep fuzzy hash
+var SetFuzzyHashFunc func(bool)
+
+// SetHashEncodingFunc is a function type for setting the checksum display encoding
+var SetHashEncodingFunc func(string) error
+
+// SetSignatureFunc is a function type for setting the detached signature and public key
+var SetSignatureFunc func(string, string)
+
+// SetRootFunc makes lookups resolve inside an alternate root directory
+var SetRootFunc func(string) error
+
+// RootPathFunc maps an absolute path argument into the alternate root
+var RootPathFunc func(string) string
+
+// SetProgressFunc configures cancellation, progress reporting and the number of files to hash
+var SetProgressFunc func(context.Context, bool, int)
+
 var noColor bool
 var searchLib bool
+var pkgConfigOnly bool
+var libMatch string
+var verbose bool
+var findProviders bool
+var reverseDeps bool
 var showHash bool
+var showSSDeep bool
 var diffMode bool
+var diffJSON bool
+var diffBrief bool
+var expect Expectations
 var showFullLinkedLibs bool
+var quiet bool
+var silent bool
+var hashEncoding string
+var verifySig string
+var diffOpts = DiffOptions{ContextLines: 3, MaxTextSize: 1024 * 1024}
+var sigKey string
+var rootDir string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -52,6 +135,8 @@ var rootCmd = &cobra.Command{
 - Symlink chain (if applicable)
 
 If the argument is not a valid path, finfo will search for it in PATH.
+The subcommand names dupes, snapshot, drift and pkg-verify are reserved; to
+inspect a file or command with one of those names, write ./dupes or finfo -- dupes.
 
 Examples:
   finfo /usr/bin/python3        # Show info for a specific file
@@ -59,8 +144,28 @@ Examples:
   finfo file1 file2 file3       # Show info for multiple files
   finfo *.so                    # Use glob patterns
   finfo --lib ssl               # Search for SSL library files
+  finfo --lib --pc ssl          # pkg-config packages for SSL (Cflags, Libs, Requires)
+  finfo --lib 'libxml*'         # Glob; also --match regex or --match fuzzy
+  finfo --lib --verbose ssl     # Full file info for every library found
+  finfo --provides EVP_MD_fetch # Which library defines a symbol (globs allowed)
+  finfo --rdeps libssl.so.3     # Binaries and libraries that load libssl.so.3
+  finfo --rdeps libssl.so.3 /opt/app  # ... within a directory tree
+  finfo --root /srv/image /usr/bin/curl  # Inspect a file inside an extracted image
+  finfo --sysroot /opt/sysroot --lib ssl # Search a cross-compilation sysroot
   finfo --hash file.zip         # Show file with checksums
+  finfo --hash --ssdeep build.bin  # Checksums plus an ssdeep fuzzy hash
+  finfo --hash -q disk.img      # Checksums without progress on stderr
+  finfo --hash-encoding base64 app.js  # Checksums in base64 (also base32, multihash)
   finfo --diff file1 file2      # Compare two files
+  finfo --diff -U 5 --word-diff a.conf b.conf  # Text diff with 5 context lines and word highlights
+  finfo --diff --exclude '*.o' dirA dirB        # Compare two directory trees
+  finfo --diff --ignore mtime,owner a b         # Compare without timestamps and ownership
+  finfo --diff -s a b && echo same              # Exit 0 same content, 1 different, 2 error
+  finfo --diff -s --strict a b                  # ... and exit 1 on metadata differences too
+  finfo --diff build1 build2 build3 deployed    # Similarity matrix of several files
+  finfo --expect sha256:9f86d08 release.tar.gz  # Check against a published checksum
+  finfo --key minisign.pub app.tar.gz   # Verify app.tar.gz.minisig / .sig
+  finfo --verify-sig app.sig --key allowed_signers app  # Verify an SSH signature
   finfo --ll cmake              # Show only linked libraries (full list)`,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
@@ -69,24 +174,100 @@ Examples:
 			SetDisableColorsFunc(noColor)
 		}
 
+		// Resolve paths, commands, libraries and packages inside another tree
+		if !applyRoot(args) {
+			os.Exit(1)
+		}
+
+		// Choosing an encoding implies --hash
+		if cmd.Flags().Changed("hash-encoding") {
+			showHash = true
+			if SetHashEncodingFunc != nil {
+				if err := SetHashEncodingFunc(hashEncoding); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		}
+
+		// The fuzzy hash is an extra --hash row, and --diff needs it to score similarity
+		if showSSDeep {
+			showHash = true
+		}
+		if SetFuzzyHashFunc != nil {
+			SetFuzzyHashFunc(showSSDeep || diffMode)
+		}
+
+		// An explicit signature file only makes sense for a single file
+		if verifySig != "" && len(args) != 1 {
+			fmt.Fprintf(os.Stderr, "Error: --verify-sig requires exactly 1 file argument\n")
+			os.Exit(1)
+		}
+		if SetSignatureFunc != nil {
+			SetSignatureFunc(verifySig, sigKey)
+		}
+
 		// Set hash calculation flag
 		if SetCalculateHashesFunc != nil {
 			SetCalculateHashesFunc(showHash)
 		}
 
+		// Progress is drawn on stderr only; stdout stays clean for piping
+		if SetProgressFunc != nil {
+			SetProgressFunc(cmd.Context(), !quiet, len(args))
+		}
+
+		// Check files against expected checksums, size or mode
+		if len(expect.Digests) > 0 || cmd.Flags().Changed("expect-size") || expect.Mode != "" {
+			if !cmd.Flags().Changed("expect-size") {
+				expect.Size = -1
+			}
+			if CheckExpectationsFunc != nil {
+				output, mismatch, err := CheckExpectationsFunc(args, expect, diffJSON, diffBrief)
+				exitIfCancelled(cmd.Context())
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(2)
+				}
+				if !silent {
+					fmt.Print(output)
+				}
+				if mismatch {
+					os.Exit(1)
+				}
+			}
+			return
+		}
+
 		// Handle diff mode
+		// Exit status follows cmp(1): 0 identical, 1 different, 2 trouble;
+		// differing metadata alone only fails with --strict
 		if diffMode {
-			if len(args) != 2 {
-				fmt.Fprintf(os.Stderr, "Error: --diff requires exactly 2 file arguments\n")
-				os.Exit(1)
+			if len(args) < 2 {
+				fmt.Fprintf(os.Stderr, "Error: --diff requires at least 2 file arguments (or 2 directories)\n")
+				os.Exit(2)
+			}
+			if diffOpts.ContextLines < 0 {
+				fmt.Fprintf(os.Stderr, "Error: --context must not be negative (got %d)\n", diffOpts.ContextLines)
+				os.Exit(2)
+			}
+			if SetDiffOptionsFunc != nil {
+				SetDiffOptionsFunc(diffOpts)
 			}
 			if CompareFilesFunc != nil {
-				output, err := CompareFilesFunc(args[0], args[1])
+				// An incomplete comparison still prints what it found before failing
+				output, differs, err := CompareFilesFunc(args, diffJSON, diffBrief)
+				exitIfCancelled(cmd.Context())
+				if !silent {
+					fmt.Print(output)
+				}
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Error comparing files: %v\n", err)
+					os.Exit(2)
+				}
+				if differs {
 					os.Exit(1)
 				}
-				fmt.Print(output)
 			}
 			return
 		}
@@ -95,7 +276,7 @@ Examples:
 		if showFullLinkedLibs {
 			for _, input := range args {
 				filePath := input
-				if _, err := os.Stat(input); os.IsNotExist(err) {
+				if _, err := os.Lstat(input); os.IsNotExist(err) {
 					if ResolveCommandFunc != nil {
 						resolved, resolveErr := ResolveCommandFunc(input)
 						if resolveErr == nil {
@@ -122,7 +303,58 @@ Examples:
 			return
 		}
 
-		// Handle library search mode
+		// Find the libraries exporting a symbol
+		if findProviders {
+			if FindSymbolProvidersFunc == nil {
+				fmt.Fprintf(os.Stderr, "Error: symbol search not available\n")
+				os.Exit(1)
+			}
+			output, found, err := FindSymbolProvidersFunc(args)
+			exitIfCancelled(cmd.Context())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Print(output)
+			if !found {
+				os.Exit(1)
+			}
+			return
+		}
+
+		// Find the binaries and libraries that load a library
+		if reverseDeps {
+			if FindReverseDepsFunc == nil {
+				fmt.Fprintf(os.Stderr, "Error: reverse dependency search not available\n")
+				os.Exit(1)
+			}
+			output, found, err := FindReverseDepsFunc(args[0], args[1:])
+			exitIfCancelled(cmd.Context())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Print(output)
+			if !found {
+				os.Exit(1)
+			}
+			return
+		}
+
+		// Handle library search mode; --pc lists pkg-config packages instead of files
+		if pkgConfigOnly {
+			if FindPkgConfigFunc == nil {
+				fmt.Fprintf(os.Stderr, "Error: pkg-config search not available\n")
+				os.Exit(1)
+			}
+			output, err := FindPkgConfigFunc(args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Print(output)
+			return
+		}
 		if searchLib {
 			if FindLibraryFunc == nil {
 				fmt.Fprintf(os.Stderr, "Error: Library search not available\n")
@@ -130,15 +362,22 @@ Examples:
 			}
 
 			input := args[0]
-			libraries, err := FindLibraryFunc(input)
+			libraries, err := FindLibraryFunc(input, libMatch)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
 
+			// One line per library unless the full file info is wanted
+			if !verbose && FormatLibrarySummaryFunc != nil {
+				fmt.Print(FormatLibrarySummaryFunc(input, libraries))
+				return
+			}
+
 			fmt.Printf("Found %d library file(s) for '%s':\n\n", len(libraries), input)
 			for i, lib := range libraries {
 				info, err := GetFileInfoFunc(lib)
+				exitIfCancelled(cmd.Context())
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", lib, err)
 					continue
@@ -160,7 +399,7 @@ Examples:
 		for _, input := range args {
 			// Try to resolve as command if not a valid path
 			filePath := input
-			if _, err := os.Stat(input); os.IsNotExist(err) {
+			if _, err := os.Lstat(input); os.IsNotExist(err) {
 				if ResolveCommandFunc != nil {
 					resolved, resolveErr := ResolveCommandFunc(input)
 					if resolveErr == nil {
@@ -182,6 +421,7 @@ Examples:
 		// Display info for each file
 		for i, filePath := range filePaths {
 			info, err := GetFileInfoFunc(filePath)
+			exitIfCancelled(cmd.Context())
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", filePath, err)
 				continue
@@ -198,15 +438,52 @@ Examples:
 	},
 }
 
+// applyRoot sets the --root directory and maps absolute path arguments into
+// it, reporting whether that succeeded
+func applyRoot(args []string) bool {
+	if rootDir == "" || SetRootFunc == nil {
+		return true
+	}
+	if err := SetRootFunc(rootDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	if RootPathFunc != nil {
+		for i, arg := range args {
+			args[i] = RootPathFunc(arg)
+		}
+	}
+	return true
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// Cancel in-flight work (e.g. hashing a large file) on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Paths that never check ctx (ldd, plain file info) would otherwise ignore
+	// Ctrl-C; restoring the default handler lets a second one kill the process
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
+		stop()
 		os.Exit(1)
 	}
 }
 
+// exitIfCancelled exits with the conventional interrupt status once ctx is cancelled
+func exitIfCancelled(ctx context.Context) {
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Interrupted")
+		os.Exit(130)
+	}
+}
+
 // SetVersion sets the version string for --version flag
 func SetVersion(v string) {
 	rootCmd.Version = v
@@ -215,8 +492,35 @@ func SetVersion(v string) {
 func init() {
 	rootCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
 	rootCmd.Flags().BoolVar(&searchLib, "lib", false, "Search for library files (.so, .a, .dylib)")
-	rootCmd.Flags().BoolVar(&showHash, "hash", false, "Calculate and show file checksums (MD5, SHA256, SHA512)")
-	rootCmd.Flags().BoolVar(&diffMode, "diff", false, "Compare two files and show differences")
+	rootCmd.Flags().BoolVar(&pkgConfigOnly, "pc", false, "With --lib, list only matching pkg-config packages (implies --lib)")
+	rootCmd.Flags().StringVar(&libMatch, "match", "", "How --lib matches NAME: name, glob, regex or fuzzy (default name, or glob if NAME has wildcards)")
+	rootCmd.Flags().BoolVar(&findProviders, "provides", false, "List the libraries whose dynamic symbol table defines each SYMBOL argument")
+	rootCmd.Flags().BoolVar(&reverseDeps, "rdeps", false, "List ELF files that need LIBRARY directly or indirectly (args: LIBRARY [DIRS...])")
+	rootCmd.Flags().StringVar(&rootDir, "root", "", "Resolve paths, commands, libraries, users and packages inside DIR (an image or sysroot)")
+	rootCmd.Flags().StringVar(&rootDir, "sysroot", "", "Alias for --root")
+	rootCmd.Flags().BoolVar(&verbose, "verbose", false, "With --lib, show full file info for every match instead of a summary")
+	rootCmd.Flags().BoolVar(&showHash, "hash", false, "Calculate and show file checksums (MD5, SHA1, SHA256, SHA384, SHA512, git, SRI, OCI)")
+	rootCmd.Flags().BoolVar(&showSSDeep, "ssdeep", false, "Also compute the ssdeep fuzzy hash (implies --hash)")
+	rootCmd.Flags().StringVar(&hashEncoding, "hash-encoding", "hex", "Checksum encoding: hex, base64, base32, multihash (implies --hash)")
+	rootCmd.Flags().BoolVar(&diffMode, "diff", false, "Compare two files (or directories) and show differences")
+	rootCmd.Flags().IntVarP(&diffOpts.ContextLines, "context", "U", 3, "Lines of context in --diff text output")
+	rootCmd.Flags().BoolVar(&diffOpts.WordDiff, "word-diff", false, "Highlight changed words within lines in --diff text output")
+	rootCmd.Flags().Int64Var(&diffOpts.MaxTextSize, "max-diff-size", 1024*1024, "Largest text file (bytes) to diff line by line in --diff")
+	rootCmd.Flags().BoolVar(&diffOpts.IgnoreVolatile, "ignore-volatile", false, "Ignore ELF build-id notes and Mach-O UUIDs when diffing binaries; archives with the same entries are equivalent")
+	rootCmd.Flags().StringArrayVar(&diffOpts.Excludes, "exclude", nil, "Glob of paths to skip when diffing directories (repeatable)")
+	rootCmd.Flags().BoolVar(&diffOpts.MetadataOnly, "metadata-only", false, "Compare directory files by size and mtime only (no hashing)")
+	rootCmd.Flags().BoolVar(&diffOpts.Detail, "detail", false, "Show the full per-file comparison for changed files when diffing directories")
+	rootCmd.Flags().StringSliceVar(&diffOpts.Ignore, "ignore", nil, "Metadata fields to leave out of --diff, e.g. mtime,owner")
+	rootCmd.Flags().BoolVar(&diffOpts.Strict, "strict", false, "With --diff, exit 1 when only metadata differs")
 	rootCmd.Flags().BoolVar(&showFullLinkedLibs, "ll", false, "Show only linked libraries (full list, no other info)")
 	rootCmd.Flags().BoolVar(&showFullLinkedLibs, "linked-libs", false, "Alias for --ll")
+	rootCmd.Flags().StringVar(&verifySig, "verify-sig", "", "Verify a detached minisign or SSH signature file")
+	rootCmd.Flags().StringVar(&sigKey, "key", "", "Public key (minisign .pub, authorized_keys or allowed_signers) for signature verification")
+	rootCmd.Flags().StringArrayVar(&expect.Digests, "expect", nil, "Expected digest, e.g. sha256:abcd... (prefixes allowed, repeatable)")
+	rootCmd.Flags().Int64Var(&expect.Size, "expect-size", 0, "Expected size in bytes")
+	rootCmd.Flags().StringVar(&expect.Mode, "expect-mode", "", "Expected permissions, octal (0755) or symbolic (-rwxr-xr-x)")
+	rootCmd.Flags().BoolVar(&diffJSON, "json", false, "Print the --diff or --expect verdict as JSON")
+	rootCmd.Flags().BoolVar(&diffBrief, "brief", false, "Print only a one-line --diff or --expect verdict")
+	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress output on stderr")
+	rootCmd.Flags().BoolVarP(&silent, "silent", "s", false, "With --diff or --expect, print nothing and only set the exit status")
 }
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// SnapshotFunc records a JSON baseline of the given paths
+var SnapshotFunc func([]string) (string, error)
+
+// DriftFunc checks a baseline (path, JSON output, ignored fields), returning
+// the report and whether anything drifted
+var DriftFunc func(string, bool, []string) (string, bool, error)
+
+var driftJSON bool
+var driftIgnore []string
+
+// snapshotCmd records a metadata baseline
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot PATH...",
+	Short: "Record a JSON baseline of file metadata and checksums",
+	Long: `Record the checksums, mode, owner, timestamps, extended attributes, file
+type and linked libraries of every file, directory and symlink under the given
+paths. The baseline is written to stdout as JSON; check it later with
+'finfo drift'.
+
+Examples:
+  finfo snapshot /usr/local/bin /etc > baseline.json
+  finfo drift baseline.json`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if SetProgressFunc != nil {
+			SetProgressFunc(cmd.Context(), false, 0)
+		}
+		if SnapshotFunc == nil {
+			fmt.Fprintf(os.Stderr, "Error: Snapshots not available\n")
+			os.Exit(2)
+		}
+
+		output, err := SnapshotFunc(args)
+		exitIfCancelled(cmd.Context())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		fmt.Print(output)
+	},
+}
+
+// driftCmd compares paths against a baseline
+var driftCmd = &cobra.Command{
+	Use:   "drift BASELINE",
+	Short: "Report what changed since a snapshot baseline",
+	Long: `Re-inspect the paths recorded by 'finfo snapshot' and report files that were
+added or removed and every property that changed.
+
+Exits 0 when nothing drifted, 1 when something did and 2 on error.
+
+Examples:
+  finfo drift baseline.json
+  finfo drift --ignore ctime,mtime baseline.json
+  finfo drift --json baseline.json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if SetDisableColorsFunc != nil {
+			SetDisableColorsFunc(noColor)
+		}
+		if SetProgressFunc != nil {
+			SetProgressFunc(cmd.Context(), false, 0)
+		}
+		if DriftFunc == nil {
+			fmt.Fprintf(os.Stderr, "Error: Drift detection not available\n")
+			os.Exit(2)
+		}
+
+		output, drifted, err := DriftFunc(args[0], driftJSON, driftIgnore)
+		exitIfCancelled(cmd.Context())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		fmt.Print(output)
+		if drifted {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	driftCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	driftCmd.Flags().BoolVar(&driftJSON, "json", false, "Output the drift report as JSON")
+	driftCmd.Flags().StringSliceVar(&driftIgnore, "ignore", nil, "Properties to leave out, e.g. ctime,mtime")
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(driftCmd)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Verdicts reported by CompareFiles
+const (
+	VerdictIdentical  = "identical"
+	VerdictEquivalent = "equivalent" // differ only in ignored volatile regions
+	VerdictMetadata   = "metadata"   // same content, different metadata
+	VerdictDifferent  = "different"
+	VerdictIncomplete = "incomplete" // nothing differs, but some paths could not be read
+)
+
+// DiffSummary is the outcome of a --diff comparison, for scripts and --brief output
+type DiffSummary struct {
+	Path1       string   `json:"path1"`
+	Path2       string   `json:"path2"`
+	Kind        string   `json:"kind"` // "file" or "directory"
+	Verdict     string   `json:"verdict"`
+	Details     string   `json:"details,omitempty"`
+	Differences []string `json:"differences,omitempty"` // fields that differ, e.g. "mtime", "content"
+	Similarity  *int     `json:"similarity,omitempty"`  // fuzzy hash score from 0 to 100
+	Unreadable  int      `json:"unreadable,omitempty"`  // paths left out because they could not be read
+	ABIBreaks   []string `json:"abi_breaks,omitempty"`  // likely ABI breaks between two ELF or Mach-O files
+	Directory   *DirDiff `json:"directory,omitempty"`
+}
+
+// Differs reports whether the compared paths should be treated as different.
+// Same content with different metadata only counts when strict is set.
+// Unreadable paths are reported by Incomplete instead.
+func (s *DiffSummary) Differs(strict bool) bool {
+	switch s.Verdict {
+	case VerdictIdentical, VerdictEquivalent, VerdictIncomplete:
+		return false
+	case VerdictMetadata:
+		return strict
+	}
+	return true
+}
+
+// Incomplete returns an error when paths were left out of the comparison, so
+// that, like diff -r, the comparison exits with trouble even if nothing differed
+func (s *DiffSummary) Incomplete() error {
+	if s.Unreadable == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d path(s) under %s and %s could not be read", s.Unreadable, s.Path1, s.Path2)
+}
+
+// FormatDiffBrief renders a summary as a single line
+func FormatDiffBrief(s *DiffSummary) string {
+	subject := "Files"
+	if s.Kind == "directory" {
+		subject = "Directories"
+	}
+
+	var line string
+	switch s.Verdict {
+	case VerdictIdentical:
+		line = fmt.Sprintf("%s %s and %s are identical", subject, s.Path1, s.Path2)
+	case VerdictEquivalent:
+		line = fmt.Sprintf("%s %s and %s are equivalent", subject, s.Path1, s.Path2)
+	case VerdictMetadata:
+		line = fmt.Sprintf("%s %s and %s have the same content but different metadata", subject, s.Path1, s.Path2)
+	case VerdictIncomplete:
+		line = fmt.Sprintf("%s %s and %s could not be fully compared", subject, s.Path1, s.Path2)
+	default:
+		line = fmt.Sprintf("%s %s and %s differ", subject, s.Path1, s.Path2)
+	}
+	if s.Details != "" {
+		line += " (" + s.Details + ")"
+	}
+	if len(s.ABIBreaks) > 0 {
+		line += "; possible ABI break: " + strings.Join(s.ABIBreaks, "; ")
+	}
+	return line + "\n"
+}
+
+// FormatDiffJSON renders a summary as JSON; brief output fits on one line
+// and leaves out the per-path directory listing
+func FormatDiffJSON(s *DiffSummary, brief bool) (string, error) {
+	var data []byte
+	var err error
+	if brief {
+		short := *s
+		short.Directory = nil
+		data, err = json.Marshal(short)
+	} else {
+		data, err = json.MarshalIndent(s, "", "  ")
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// HashEncoding selects how plain digests are displayed: hex, base64, base32 or multihash
+var HashEncoding = "hex"
+
+// HashEncodings lists the supported values for HashEncoding
+var HashEncodings = []string{"hex", "base64", "base32", "multihash"}
+
+// multihashCodes maps algorithm names to their multicodec identifiers
+var multihashCodes = map[string]uint64{
+	"md5":    0xd5,
+	"sha1":   0x11,
+	"sha256": 0x12,
+	"sha512": 0x13,
+	"sha384": 0x20,
+}
+
+// SetHashEncoding validates and sets the digest display encoding
+func SetHashEncoding(encoding string) error {
+	encoding = strings.ToLower(encoding)
+	for _, e := range HashEncodings {
+		if e == encoding {
+			HashEncoding = encoding
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown hash encoding '%s' (expected one of: %s)", encoding, strings.Join(HashEncodings, ", "))
+}
+
+// gitBlobHeader returns the object header git hashes before blob content
+func gitBlobHeader(size int64) []byte {
+	return []byte(fmt.Sprintf("blob %d\x00", size))
+}
+
+// sriString formats a Subresource Integrity value, e.g. sha384-<base64>
+func sriString(algo string, sum []byte) string {
+	return algo + "-" + base64.StdEncoding.EncodeToString(sum)
+}
+
+// ociDigest formats an OCI/Docker content digest, e.g. sha256:<hex>
+func ociDigest(algo string, sum []byte) string {
+	return algo + ":" + hex.EncodeToString(sum)
+}
+
+// encodeDigest re-encodes a hex digest in the requested encoding
+func encodeDigest(hexDigest, algo, encoding string) string {
+	if encoding == "" || encoding == "hex" {
+		return hexDigest
+	}
+	sum, err := hex.DecodeString(hexDigest)
+	if err != nil {
+		return hexDigest
+	}
+
+	switch encoding {
+	case "base64":
+		return base64.StdEncoding.EncodeToString(sum)
+	case "base32":
+		return base32.StdEncoding.EncodeToString(sum)
+	case "multihash":
+		code, ok := multihashCodes[algo]
+		if !ok {
+			return hexDigest
+		}
+		return base58Encode(multihash(code, sum))
+	}
+	return hexDigest
+}
+
+// multihash prefixes a digest with its varint algorithm code and length
+func multihash(code uint64, sum []byte) []byte {
+	buf := appendUvarint(nil, code)
+	buf = appendUvarint(buf, uint64(len(sum)))
+	return append(buf, sum...)
+}
+
+// appendUvarint appends an unsigned LEB128 varint, as used by multiformats
+func appendUvarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+// base58Alphabet is the Bitcoin base58 alphabet used for multihash strings
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Encode encodes data as base58btc
+func base58Encode(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	// Leading zero bytes are encoded as '1'
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
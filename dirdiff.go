@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dirEntry is one path found while walking a directory tree
+type dirEntry struct {
+	info   os.FileInfo
+	target string // symlink target
+	err    error  // why the entry, or a directory's contents, could not be read
+}
+
+// DirChange is a single difference between two trees
+type DirChange struct {
+	Path   string `json:"path"` // relative to the compared roots
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// DirDiff is the result of comparing two directory trees
+type DirDiff struct {
+	OnlyInA       []string    `json:"only_in_a,omitempty"`
+	OnlyInB       []string    `json:"only_in_b,omitempty"`
+	Content       []DirChange `json:"content,omitempty"`
+	Type          []DirChange `json:"type,omitempty"`
+	Mode          []DirChange `json:"mode,omitempty"`
+	Owner         []DirChange `json:"owner,omitempty"`
+	SymlinkTarget []DirChange `json:"symlink_target,omitempty"`
+	Compared      int         `json:"compared"`
+	Identical     int         `json:"identical"`
+	Warnings      []string    `json:"warnings,omitempty"` // unreadable paths, left out of the comparison
+}
+
+// Differs reports whether any difference was found
+func (d *DirDiff) Differs() bool {
+	return d.ContentDiffers() || len(d.Mode)+len(d.Owner) > 0
+}
+
+// ContentDiffers reports whether the trees differ in more than mode and ownership
+func (d *DirDiff) ContentDiffers() bool {
+	return len(d.OnlyInA)+len(d.OnlyInB)+len(d.Content)+len(d.Type)+len(d.SymlinkTarget) > 0
+}
+
+// CompareDirectories walks two trees and reports what differs between them.
+// Paths matching any exclude glob (by relative path or base name) are skipped;
+// with metadataOnly, files of equal size and mtime are assumed identical. The
+// mode, owner, group, mtime and symlink fields in ignore are not compared.
+func CompareDirectories(dirA, dirB string, excludes, ignore []string, metadataOnly bool) (*DirDiff, error) {
+	// WalkDir doesn't descend into a root that is a symlink, so walk its target
+	dirA, err := evalSymlinks(dirA)
+	if err != nil {
+		return nil, err
+	}
+	dirB, err = evalSymlinks(dirB)
+	if err != nil {
+		return nil, err
+	}
+
+	treeA, err := walkTree(dirA, excludes)
+	if err != nil {
+		return nil, err
+	}
+	treeB, err := walkTree(dirB, excludes)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DirDiff{}
+	var unreadable []string // directories whose contents are skipped on both sides
+	for _, rel := range unionKeys(treeA, treeB) {
+		a, inA := treeA[rel]
+		b, inB := treeB[rel]
+
+		if underAny(rel, unreadable) {
+			continue
+		}
+		if a.err != nil || b.err != nil {
+			for _, e := range []dirEntry{a, b} {
+				if e.err != nil {
+					d.Warnings = append(d.Warnings, e.err.Error())
+				}
+			}
+			if (a.info != nil && a.info.IsDir()) || (b.info != nil && b.info.IsDir()) {
+				unreadable = append(unreadable, rel+"/")
+			}
+			continue
+		}
+
+		// A directory on one side only is reported once, not per descendant
+		if !inA {
+			if !underAny(rel, d.OnlyInB) {
+				d.OnlyInB = append(d.OnlyInB, displayRel(rel, b.info))
+			}
+			continue
+		}
+		if !inB {
+			if !underAny(rel, d.OnlyInA) {
+				d.OnlyInA = append(d.OnlyInA, displayRel(rel, a.info))
+			}
+			continue
+		}
+
+		d.Compared++
+		same := true
+
+		typeA, typeB := a.info.Mode().Type(), b.info.Mode().Type()
+		if typeA != typeB {
+			d.Type = append(d.Type, DirChange{rel, fileKind(a.info), fileKind(b.info)})
+			continue
+		}
+
+		switch {
+		case typeA&os.ModeSymlink != 0:
+			if a.target != b.target && !ignoresField(ignore, "symlink") {
+				d.SymlinkTarget = append(d.SymlinkTarget, DirChange{rel, a.target, b.target})
+				same = false
+			}
+		case typeA.IsRegular():
+			changed, detail, err := contentChanged(filepath.Join(dirA, rel), filepath.Join(dirB, rel), a.info, b.info, metadataOnly, ignore)
+			if errors.Is(err, context.Canceled) {
+				return nil, err
+			}
+			if err != nil {
+				d.Warnings = append(d.Warnings, err.Error())
+				continue
+			}
+			if changed {
+				d.Content = append(d.Content, DirChange{Path: rel, Before: detail})
+				same = false
+			}
+		}
+
+		if a.info.Mode().Perm() != b.info.Mode().Perm() && typeA&os.ModeSymlink == 0 && !ignoresField(ignore, "mode") {
+			d.Mode = append(d.Mode, DirChange{rel, a.info.Mode().String(), b.info.Mode().String()})
+			same = false
+		}
+		if ownerA, ownerB := ownerString(a.info, ignore), ownerString(b.info, ignore); ownerA != ownerB {
+			d.Owner = append(d.Owner, DirChange{rel, ownerA, ownerB})
+			same = false
+		}
+
+		if same {
+			d.Identical++
+		}
+	}
+	return d, nil
+}
+
+// walkTree collects every path under root, keyed by relative path. Paths
+// that can't be read are kept with their error rather than ending the walk,
+// as --dupes does.
+func walkTree(root string, excludes []string) (map[string]dirEntry, error) {
+	tree := make(map[string]dirEntry)
+	err := filepath.WalkDir(root, func(path string, de fs.DirEntry, walkErr error) error {
+		if err := HashContext.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if walkErr != nil {
+			if rel == "." {
+				return walkErr
+			}
+			// WalkDir reports a directory it can't list after visiting it
+			entry := tree[rel]
+			entry.err = walkErr
+			tree[rel] = entry
+			return nil
+		}
+		if rel == "." {
+			return nil
+		}
+		if excluded(rel, excludes) {
+			if de.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := de.Info()
+		if err != nil {
+			tree[rel] = dirEntry{err: err}
+			return nil
+		}
+		entry := dirEntry{info: info}
+		if info.Mode()&os.ModeSymlink != 0 {
+			entry.target, _ = os.Readlink(path)
+		}
+		tree[rel] = entry
+		return nil
+	})
+	return tree, err
+}
+
+// excluded reports whether a relative path matches any exclude glob
+func excluded(rel string, excludes []string) bool {
+	for _, pattern := range excludes {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// underAny reports whether rel is inside one of the listed directories
+func underAny(rel string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasSuffix(dir, "/") && strings.HasPrefix(rel, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// displayRel marks directories with a trailing slash
+func displayRel(rel string, info os.FileInfo) string {
+	if info.IsDir() {
+		return rel + "/"
+	}
+	return rel
+}
+
+// fileKind describes the type of a file for display
+func fileKind(info os.FileInfo) string {
+	switch mode := info.Mode(); {
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode.IsRegular():
+		return "regular file"
+	default:
+		return mode.Type().String()
+	}
+}
+
+// ownerString formats a file's uid:gid, leaving out an ignored owner or group
+func ownerString(info os.FileInfo, ignore []string) string {
+	uid, gid, ok := fileOwnership(info)
+	if !ok {
+		return ""
+	}
+	owner, group := fmt.Sprint(uid), fmt.Sprint(gid)
+	if ignoresField(ignore, "owner") {
+		owner = ""
+	}
+	if ignoresField(ignore, "group") {
+		group = ""
+	}
+	return owner + ":" + group
+}
+
+// contentChanged compares two regular files, returning a short description of
+// the change. With metadataOnly and mtime ignored, only sizes are compared.
+func contentChanged(pathA, pathB string, a, b os.FileInfo, metadataOnly bool, ignore []string) (bool, string, error) {
+	if a.Size() != b.Size() {
+		return true, fmt.Sprintf("%d → %d bytes", a.Size(), b.Size()), nil
+	}
+	if metadataOnly {
+		if !a.ModTime().Equal(b.ModTime()) && !ignoresField(ignore, "mtime") {
+			return true, "modified " + a.ModTime().Format("2006-01-02 15:04:05") + " → " + b.ModTime().Format("2006-01-02 15:04:05"), nil
+		}
+		return false, "", nil
+	}
+	sumA, err := sha256File(pathA, -1)
+	if err != nil {
+		return false, "", err
+	}
+	sumB, err := sha256File(pathB, -1)
+	if err != nil {
+		return false, "", err
+	}
+	if sumA != sumB {
+		return true, "same size, different content", nil
+	}
+	return false, "", nil
+}
+
+// FormatDirDiff formats a directory comparison with colors
+func FormatDirDiff(d *DirDiff, dirA, dirB string, labelFn, treeFn, matchFn, diffFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("diff -r "+filepath.Base(dirA)+" "+filepath.Base(dirB)))
+	fmt.Fprintf(&sb, "%s %s\n", diffFn("---"), valueFn(dirA))
+	fmt.Fprintf(&sb, "%s %s\n", matchFn("+++"), valueFn(dirB))
+
+	list := func(title string, items []string, sign string, fn func(a ...interface{}) string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "\n%s\n", labelFn(title))
+		for _, item := range items {
+			fmt.Fprintf(&sb, "  %s %s\n", fn(sign), valueFn(item))
+		}
+	}
+	changes := func(title string, items []DirChange) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "\n%s\n", labelFn(title))
+		for _, c := range items {
+			if c.After == "" {
+				fmt.Fprintf(&sb, "  %s %s %s\n", diffFn("✗"), valueFn(c.Path), treeFn("("+c.Before+")"))
+			} else {
+				fmt.Fprintf(&sb, "  %s %s: %s → %s\n", diffFn("✗"), valueFn(c.Path), valueFn(c.Before), valueFn(c.After))
+			}
+		}
+	}
+
+	list("Only in "+dirA+":", d.OnlyInA, "-", diffFn)
+	list("Only in "+dirB+":", d.OnlyInB, "+", matchFn)
+	changes("Changed content:", d.Content)
+	changes("Changed type:", d.Type)
+	changes("Changed mode:", d.Mode)
+	changes("Changed owner:", d.Owner)
+	changes("Changed symlink targets:", d.SymlinkTarget)
+	list("Not compared:", d.Warnings, "!", diffFn)
+
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Summary:"))
+	rows := [][2]string{
+		{"Compared  :", fmt.Sprintf("%d", d.Compared)},
+		{"Identical :", fmt.Sprintf("%d", d.Identical)},
+		{"Only in A :", fmt.Sprintf("%d", len(d.OnlyInA))},
+		{"Only in B :", fmt.Sprintf("%d", len(d.OnlyInB))},
+		{"Changed   :", fmt.Sprintf("%d content, %d type, %d mode, %d owner, %d symlink",
+			len(d.Content), len(d.Type), len(d.Mode), len(d.Owner), len(d.SymlinkTarget))},
+	}
+	if len(d.Warnings) > 0 {
+		rows = append(rows, [2]string{"Unreadable:", fmt.Sprintf("%d (not compared)", len(d.Warnings))})
+	}
+	for i, row := range rows {
+		branch := "├─"
+		if i == len(rows)-1 {
+			branch = "╰─"
+		}
+		fmt.Fprintf(&sb, "  %s %s %s\n", treeFn(branch), treeFn(row[0]), valueFn(row[1]))
+	}
+
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Verdict:"))
+	if d.ContentDiffers() {
+		fmt.Fprintf(&sb, "  %s\n", diffFn("✗ Directories are DIFFERENT"))
+	} else if d.Differs() {
+		fmt.Fprintf(&sb, "  %s\n", diffFn("✗ Directories have the same content but DIFFERENT metadata"))
+	} else if len(d.Warnings) > 0 {
+		fmt.Fprintf(&sb, "  %s\n", diffFn(fmt.Sprintf("✗ Directories could not be fully compared (%d unreadable path(s))", len(d.Warnings))))
+	} else {
+		fmt.Fprintf(&sb, "  %s\n", matchFn("✓ Directories are IDENTICAL"))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// compareDirectoriesOutput runs a directory comparison and, with DiffOpts.Detail,
+// appends the per-file comparison of every changed file
+func compareDirectoriesOutput(dirA, dirB string, labelFn, treeFn, matchFn, diffFn, valueFn func(a ...interface{}) string) (string, *DiffSummary, error) {
+	d, err := CompareDirectories(dirA, dirB, DiffOpts.Excludes, DiffOpts.Ignore, DiffOpts.MetadataOnly)
+	if err != nil {
+		return "", nil, err
+	}
+
+	summary := &DiffSummary{Path1: dirA, Path2: dirB, Kind: "directory", Verdict: VerdictIdentical,
+		Unreadable: len(d.Warnings), Directory: d}
+	var details []string
+	if d.Differs() {
+		summary.Verdict = VerdictDifferent
+		if !d.ContentDiffers() {
+			summary.Verdict = VerdictMetadata
+		}
+		details = append(details, fmt.Sprintf("%d only in %s, %d only in %s, %d changed",
+			len(d.OnlyInA), dirA, len(d.OnlyInB), dirB,
+			len(d.Content)+len(d.Type)+len(d.Mode)+len(d.Owner)+len(d.SymlinkTarget)))
+	} else if len(d.Warnings) > 0 {
+		summary.Verdict = VerdictIncomplete
+	}
+	if len(d.Warnings) > 0 {
+		details = append(details, fmt.Sprintf("%d unreadable", len(d.Warnings)))
+	}
+	summary.Details = strings.Join(details, ", ")
+
+	var sb strings.Builder
+	sb.WriteString(FormatDirDiff(d, dirA, dirB, labelFn, treeFn, matchFn, diffFn, valueFn))
+	if DiffOpts.Detail {
+		paths := make([]string, 0, len(d.Content))
+		for _, c := range d.Content {
+			paths = append(paths, c.Path)
+		}
+		sort.Strings(paths)
+		for _, rel := range paths {
+			out, _, err := CompareFiles(filepath.Join(dirA, rel), filepath.Join(dirB, rel), labelFn, treeFn, matchFn, diffFn, valueFn)
+			if err != nil {
+				return "", nil, err
+			}
+			sb.WriteString(strings.Repeat("─", 80) + "\n")
+			sb.WriteString(out)
+		}
+	}
+	return sb.String(), summary, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTree creates files under dir from a map of relative path to content
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCompareDirectoriesSymlinkedRoots(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, filepath.Join(dir, "d1"), map[string]string{"same": "x\n", "f": "a\n"})
+	writeTree(t, filepath.Join(dir, "d2"), map[string]string{"same": "x\n", "f": "b\n"})
+	for _, link := range []string{"l1", "l2"} {
+		if err := os.Symlink("d"+link[1:], filepath.Join(dir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := CompareDirectories(filepath.Join(dir, "l1"), filepath.Join(dir, "l2"), nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Compared != 2 || d.Identical != 1 {
+		t.Errorf("compared %d, identical %d; want 2 and 1", d.Compared, d.Identical)
+	}
+	if len(d.Content) != 1 || d.Content[0].Path != "f" {
+		t.Errorf("content changes = %+v, want f", d.Content)
+	}
+}
+
+func TestCompareDirectoriesUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read any directory")
+	}
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeTree(t, a, map[string]string{"f": "1\n", "x/s": "s\n"})
+	writeTree(t, b, map[string]string{"f": "1\n", "x/s": "s\n"})
+	locked := filepath.Join(a, "x")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(locked, 0o755) })
+
+	plain := func(a ...interface{}) string { return fmt.Sprint(a...) }
+	_, summary, err := compareDirectoriesOutput(a, b, plain, plain, plain, plain, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Verdict != VerdictIncomplete || summary.Unreadable != 1 {
+		t.Errorf("got verdict %s with %d unreadable, want incomplete with 1", summary.Verdict, summary.Unreadable)
+	}
+	if summary.Incomplete() == nil {
+		t.Error("an incomplete comparison reported no error")
+	}
+}
+
+func TestCompareDirectoriesIgnore(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeTree(t, a, map[string]string{"f": "1\n"})
+	writeTree(t, b, map[string]string{"f": "1\n"})
+	if err := os.Chmod(filepath.Join(b, "f"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(b, "f"), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := CompareDirectories(a, b, nil, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Mode) != 1 || len(d.Content) != 1 {
+		t.Errorf("got %d mode and %d content changes, want 1 and 1", len(d.Mode), len(d.Content))
+	}
+
+	d, err = CompareDirectories(a, b, nil, []string{"mode", "mtime"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Differs() || d.Identical != 1 {
+		t.Errorf("ignored fields were compared: %+v", d)
+	}
+}
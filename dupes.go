@@ -0,0 +1,295 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// partialHashSize is how much of each file is hashed before a full hash is needed
+const partialHashSize = 64 * 1024
+
+// DuplicateFile is one member of a duplicate group
+type DuplicateFile struct {
+	Path       string `json:"path"`
+	HardlinkOf string `json:"hardlink_of,omitempty"` // set when already hardlinked to an earlier member
+}
+
+// DuplicateGroup is a set of files with identical content
+type DuplicateGroup struct {
+	Size   int64           `json:"size"`
+	SHA256 string          `json:"sha256"`
+	Files  []DuplicateFile `json:"files"`
+	Wasted int64           `json:"wasted_bytes"` // size × (distinct inodes - 1)
+}
+
+// DuplicateReport is the result of a duplicate search
+type DuplicateReport struct {
+	Groups       []DuplicateGroup `json:"groups"`
+	FilesScanned int              `json:"files_scanned"`
+	TotalWasted  int64            `json:"wasted_bytes"`
+}
+
+// DedupOperation is a single step in a deduplication plan
+type DedupOperation struct {
+	Op     string `json:"op"` // "hardlink" or "remove"
+	Path   string `json:"path"`
+	Target string `json:"target,omitempty"` // file kept; link source for hardlink
+}
+
+// DedupPlan describes how to reclaim space; it is never executed by finfo
+type DedupPlan struct {
+	Action      string           `json:"action"`
+	Operations  []DedupOperation `json:"operations"`
+	Reclaimable int64            `json:"reclaimable_bytes"`
+}
+
+// dupeCandidate is a regular file considered during the search
+type dupeCandidate struct {
+	path     string
+	size     int64
+	dev, ino uint64
+	hasID    bool
+}
+
+// FindDuplicates walks paths and groups files with identical content.
+// Files are grouped by size, then by a hash of their first 64 KiB, then by full SHA256.
+func FindDuplicates(paths []string) (*DuplicateReport, error) {
+	bySize := make(map[int64][]dupeCandidate)
+	seen := make(map[string]bool)
+	scanned := 0
+
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				return nil
+			}
+			if err := HashContext.Err(); err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			absPath, err := filepath.Abs(path)
+			if err != nil || seen[absPath] {
+				return nil
+			}
+			seen[absPath] = true
+
+			info, err := d.Info()
+			if err != nil || info.Size() == 0 {
+				return nil
+			}
+			c := dupeCandidate{path: absPath, size: info.Size()}
+			c.dev, c.ino, _, c.hasID = fileIdentity(info)
+			bySize[c.size] = append(bySize[c.size], c)
+			scanned++
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := &DuplicateReport{Groups: []DuplicateGroup{}, FilesScanned: scanned}
+	for size, candidates := range bySize {
+		if len(candidates) < 2 {
+			continue
+		}
+
+		// Narrow down with a cheap partial hash before reading whole files
+		for partialSum, partial := range groupByHash(candidates, partialHashSize) {
+			full := map[string][]dupeCandidate{partialSum: partial}
+			if size > partialHashSize {
+				full = groupByHash(partial, -1)
+			}
+			for sum, group := range full {
+				report.Groups = append(report.Groups, newDuplicateGroup(size, sum, group))
+			}
+		}
+		if err := HashContext.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Largest waste first, then by path for stable output
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if a.Wasted != b.Wasted {
+			return a.Wasted > b.Wasted
+		}
+		return a.Files[0].Path < b.Files[0].Path
+	})
+	for _, g := range report.Groups {
+		report.TotalWasted += g.Wasted
+	}
+	return report, nil
+}
+
+// groupByHash hashes up to limit bytes of each file (-1 for all) and
+// returns only the groups with more than one member
+func groupByHash(candidates []dupeCandidate, limit int64) map[string][]dupeCandidate {
+	groups := make(map[string][]dupeCandidate)
+	for _, c := range candidates {
+		sum, err := sha256File(c.path, limit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		groups[sum] = append(groups[sum], c)
+	}
+	for sum, group := range groups {
+		if len(group) < 2 {
+			delete(groups, sum)
+		}
+	}
+	return groups
+}
+
+// sha256File hashes up to limit bytes of a file (-1 for the whole file)
+func sha256File(path string, limit int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	var r io.Reader = &contextReader{ctx: HashContext, r: file}
+	if limit >= 0 {
+		r = io.LimitReader(r, limit)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// newDuplicateGroup builds a group, marking members that already share an inode
+func newDuplicateGroup(size int64, sum string, members []dupeCandidate) DuplicateGroup {
+	sort.Slice(members, func(i, j int) bool { return members[i].path < members[j].path })
+
+	type inode struct{ dev, ino uint64 }
+	firstPath := make(map[inode]string)
+	g := DuplicateGroup{Size: size, SHA256: sum}
+	distinct := 0
+
+	for _, m := range members {
+		f := DuplicateFile{Path: m.path}
+		if m.hasID {
+			key := inode{m.dev, m.ino}
+			if first, ok := firstPath[key]; ok {
+				f.HardlinkOf = first
+			} else {
+				firstPath[key] = m.path
+				distinct++
+			}
+		} else {
+			distinct++
+		}
+		g.Files = append(g.Files, f)
+	}
+
+	g.Wasted = size * int64(distinct-1)
+	return g
+}
+
+// ValidatePlanAction checks a --plan action, so a typo fails before the scan
+func ValidatePlanAction(action string) error {
+	if action != "hardlink" && action != "remove" {
+		return fmt.Errorf("unknown plan action '%s' (expected hardlink or remove)", action)
+	}
+	return nil
+}
+
+// PlanDeduplication turns a report into a hardlink or remove plan. The first
+// file of each group is kept; files already hardlinked to it are skipped.
+func PlanDeduplication(report *DuplicateReport, action string) (*DedupPlan, error) {
+	if err := ValidatePlanAction(action); err != nil {
+		return nil, err
+	}
+
+	plan := &DedupPlan{Action: action, Operations: []DedupOperation{}}
+	for _, g := range report.Groups {
+		keep := g.Files[0].Path
+		for _, f := range g.Files[1:] {
+			// Hardlinks of the kept file are already deduplicated
+			if f.HardlinkOf == keep {
+				continue
+			}
+			plan.Operations = append(plan.Operations, DedupOperation{Op: action, Path: f.Path, Target: keep})
+			// Space comes back once every link to an inode is gone
+			if f.HardlinkOf == "" {
+				plan.Reclaimable += g.Size
+			}
+		}
+	}
+	return plan, nil
+}
+
+// FormatDuplicatesJSON renders a report, or a plan when action is set, as JSON
+func FormatDuplicatesJSON(report *DuplicateReport, action string) (string, error) {
+	var v interface{} = report
+	if action != "" {
+		plan, err := PlanDeduplication(report, action)
+		if err != nil {
+			return "", err
+		}
+		v = plan
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// FormatDuplicates formats a duplicate report for display with colors
+func FormatDuplicates(report *DuplicateReport, labelFn, treeFn, pathFn, sizeFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+
+	if len(report.Groups) == 0 {
+		fmt.Fprintf(&sb, "No duplicates found among %d file(s)\n", report.FilesScanned)
+		return sb.String()
+	}
+
+	for i, g := range report.Groups {
+		fmt.Fprintf(&sb, "%s %s × %s  %s %s\n",
+			labelFn(fmt.Sprintf("Group %d:", i+1)),
+			valueFn(len(g.Files)),
+			sizeFn(formatBytes(g.Size)),
+			treeFn("wasted"),
+			sizeFn(formatBytes(g.Wasted)))
+		fmt.Fprintf(&sb, "  %s %s\n", treeFn("SHA256:"), valueFn(g.SHA256))
+		for j, f := range g.Files {
+			branch := "├──"
+			if j == len(g.Files)-1 {
+				branch = "╰──"
+			}
+			fmt.Fprintf(&sb, "  %s %s", treeFn(branch), pathFn(f.Path))
+			if f.HardlinkOf != "" {
+				fmt.Fprintf(&sb, " %s", valueFn("(hardlink of "+f.HardlinkOf+")"))
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(labelFn("Summary:\n"))
+	files := 0
+	for _, g := range report.Groups {
+		files += len(g.Files)
+	}
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Scanned :"), valueFn(fmt.Sprintf("%d file(s)", report.FilesScanned)))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Groups  :"), valueFn(fmt.Sprintf("%d (%d files)", len(report.Groups), files)))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("╰─"), treeFn("Wasted  :"), sizeFn(formatBytes(report.TotalWasted)))
+	return sb.String()
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// minDigestPrefix is the shortest abbreviated digest accepted, as with git short hashes
+const minDigestPrefix = 7
+
+// Expectations are reference values a file is checked against
+type Expectations struct {
+	Digests []string // "algo:hex", SRI "algo-base64", or bare hex
+	Size    int64    // -1 when not checked
+	Mode    string   // octal ("0755") or symbolic ("-rwxr-xr-x"); empty when not checked
+}
+
+// ExpectCheck is the outcome of one expectation
+type ExpectCheck struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Match    bool   `json:"match"`
+	Note     string `json:"note,omitempty"`
+}
+
+// ExpectResult is the outcome of checking one file
+type ExpectResult struct {
+	Path   string        `json:"path"`
+	Checks []ExpectCheck `json:"checks"`
+	Match  bool          `json:"match"`
+}
+
+// expectDigests maps algorithm names to the matching HashInfo field
+var expectDigests = []struct {
+	name  string
+	value func(*HashInfo) string
+}{
+	{"md5", func(h *HashInfo) string { return h.MD5 }},
+	{"sha1", func(h *HashInfo) string { return h.SHA1 }},
+	{"sha256", func(h *HashInfo) string { return h.SHA256 }},
+	{"sha384", func(h *HashInfo) string { return h.SHA384 }},
+	{"sha512", func(h *HashInfo) string { return h.SHA512 }},
+	{"git-sha1", func(h *HashInfo) string { return h.GitSHA1 }},
+	{"git-sha256", func(h *HashInfo) string { return h.GitSHA256 }},
+}
+
+// CheckExpectations compares a file against expected digests, size and mode
+func CheckExpectations(path string, exp Expectations) (*ExpectResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	result := &ExpectResult{Path: path, Checks: []ExpectCheck{}, Match: true}
+	add := func(c ExpectCheck) {
+		result.Checks = append(result.Checks, c)
+		result.Match = result.Match && c.Match
+	}
+
+	if len(exp.Digests) > 0 {
+		hashes, err := CalculateHashes(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, digest := range exp.Digests {
+			c, err := checkDigest(digest, hashes)
+			if err != nil {
+				return nil, err
+			}
+			add(c)
+		}
+	}
+
+	if exp.Size >= 0 {
+		add(ExpectCheck{
+			Field:    "size",
+			Expected: fmt.Sprintf("%d bytes", exp.Size),
+			Actual:   fmt.Sprintf("%d bytes", info.Size()),
+			Match:    exp.Size == info.Size(),
+		})
+	}
+
+	if exp.Mode != "" {
+		c, err := checkMode(exp.Mode, info.Mode())
+		if err != nil {
+			return nil, err
+		}
+		add(c)
+	}
+	return result, nil
+}
+
+// checkDigest matches one expected digest, accepting abbreviated hex prefixes
+func checkDigest(expected string, hashes *HashInfo) (ExpectCheck, error) {
+	algo, value := "", strings.TrimSpace(expected)
+	if i := strings.Index(value, ":"); i >= 0 {
+		algo, value = strings.ToLower(value[:i]), value[i+1:]
+	} else if i := strings.Index(value, "-"); i >= 0 && strings.HasPrefix(value, "sha") {
+		// Subresource Integrity form, e.g. sha384-<base64>
+		raw, err := base64.StdEncoding.DecodeString(value[i+1:])
+		if err != nil {
+			return ExpectCheck{}, fmt.Errorf("invalid SRI digest '%s': %w", expected, err)
+		}
+		algo, value = strings.ToLower(value[:i]), hex.EncodeToString(raw)
+	}
+	value = strings.ToLower(value)
+
+	if value == "" || strings.Trim(value, "0123456789abcdef") != "" {
+		return ExpectCheck{}, fmt.Errorf("invalid digest '%s' (expected hex, optionally prefixed by an algorithm like sha256:)", expected)
+	}
+	if len(value) < minDigestPrefix {
+		return ExpectCheck{}, fmt.Errorf("digest '%s' is too short (at least %d hex digits are needed)", expected, minDigestPrefix)
+	}
+
+	// Without an algorithm, any digest with the same length or prefix is accepted
+	var candidates []string
+	for _, d := range expectDigests {
+		if algo == "" || algo == d.name {
+			candidates = append(candidates, d.name)
+		}
+	}
+	if len(candidates) == 0 {
+		var names []string
+		for _, d := range expectDigests {
+			names = append(names, d.name)
+		}
+		return ExpectCheck{}, fmt.Errorf("unknown digest algorithm '%s' (expected one of: %s)", algo, strings.Join(names, ", "))
+	}
+
+	var mismatch *ExpectCheck
+	for _, name := range candidates {
+		actual := digestByName(name, hashes)
+		if len(value) > len(actual) {
+			continue
+		}
+		c := ExpectCheck{Field: name, Expected: value, Actual: actual, Match: strings.HasPrefix(actual, value)}
+		if c.Match {
+			if len(value) < len(actual) {
+				c.Note = fmt.Sprintf("prefix match, %d of %d digits", len(value), len(actual))
+			}
+			return c, nil
+		}
+		// Prefer reporting the algorithm whose digest length matches
+		if mismatch == nil || len(actual) == len(value) {
+			mismatch = &c
+		}
+	}
+	if mismatch == nil {
+		return ExpectCheck{}, fmt.Errorf("digest '%s' is longer than any %s digest", expected, strings.Join(candidates, "/"))
+	}
+	if algo == "" && len(mismatch.Actual) != len(value) {
+		mismatch.Field = "digest"
+		mismatch.Actual = "no " + strings.Join(candidates, "/") + " digest has this prefix"
+	}
+	return *mismatch, nil
+}
+
+// digestByName returns the hex digest for an algorithm name
+func digestByName(name string, hashes *HashInfo) string {
+	for _, d := range expectDigests {
+		if d.name == name {
+			return d.value(hashes)
+		}
+	}
+	return ""
+}
+
+// checkMode compares permission bits given in octal or symbolic form
+func checkMode(expected string, actual os.FileMode) (ExpectCheck, error) {
+	const bits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+	c := ExpectCheck{Field: "mode", Expected: expected}
+
+	if n, err := strconv.ParseUint(expected, 8, 32); err == nil {
+		want := os.FileMode(n) & os.ModePerm
+		if n&0o4000 != 0 {
+			want |= os.ModeSetuid
+		}
+		if n&0o2000 != 0 {
+			want |= os.ModeSetgid
+		}
+		if n&0o1000 != 0 {
+			want |= os.ModeSticky
+		}
+		c.Actual = fmt.Sprintf("%04o (%s)", octalMode(actual), lsMode(actual))
+		c.Match = actual&bits == want
+		return c, nil
+	}
+
+	// Symbolic as ls writes it, with or without the leading file type character
+	symbolic := lsMode(actual)
+	switch len(expected) {
+	case 9:
+		symbolic = symbolic[1:]
+	case 10: // compared with the type character
+	default:
+		return c, fmt.Errorf("invalid mode '%s' (expected octal like 0755 or symbolic like -rwxr-xr-x)", expected)
+	}
+	c.Actual = symbolic
+	c.Match = symbolic == expected
+	return c, nil
+}
+
+// lsMode renders a mode as ls -l does. Go's FileMode.String puts the setuid,
+// setgid and sticky bits in front ("urwxr-xr-x"); ls folds them into the
+// execute columns as s/S and t/T ("-rwsr-xr-x").
+func lsMode(mode os.FileMode) string {
+	b := []byte("-rwxrwxrwx")
+	switch {
+	case mode&os.ModeDir != 0:
+		b[0] = 'd'
+	case mode&os.ModeSymlink != 0:
+		b[0] = 'l'
+	case mode&os.ModeCharDevice != 0:
+		b[0] = 'c'
+	case mode&os.ModeDevice != 0:
+		b[0] = 'b'
+	case mode&os.ModeNamedPipe != 0:
+		b[0] = 'p'
+	case mode&os.ModeSocket != 0:
+		b[0] = 's'
+	}
+	for i := 0; i < 9; i++ {
+		if mode&(1<<uint(8-i)) == 0 {
+			b[i+1] = '-'
+		}
+	}
+	special := func(set bool, i int, lower byte) {
+		if !set {
+			return
+		}
+		if b[i] == 'x' {
+			b[i] = lower
+		} else {
+			b[i] = lower - 'a' + 'A'
+		}
+	}
+	special(mode&os.ModeSetuid != 0, 3, 's')
+	special(mode&os.ModeSetgid != 0, 6, 's')
+	special(mode&os.ModeSticky != 0, 9, 't')
+	return string(b)
+}
+
+// octalMode converts permission and special bits back to their octal form
+func octalMode(mode os.FileMode) uint32 {
+	n := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		n |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		n |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		n |= 0o1000
+	}
+	return n
+}
+
+// FormatExpectJSON renders results as JSON; brief output fits on one line
+func FormatExpectJSON(results []*ExpectResult, brief bool) (string, error) {
+	var data []byte
+	var err error
+	if brief {
+		data, err = json.Marshal(results)
+	} else {
+		data, err = json.MarshalIndent(results, "", "  ")
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// FormatExpectBrief renders one line per file
+func FormatExpectBrief(results []*ExpectResult) string {
+	var sb strings.Builder
+	for _, r := range results {
+		if r.Match {
+			fmt.Fprintf(&sb, "%s: OK\n", r.Path)
+			continue
+		}
+		var failed []string
+		for _, c := range r.Checks {
+			if !c.Match {
+				failed = append(failed, c.Field)
+			}
+		}
+		fmt.Fprintf(&sb, "%s: MISMATCH (%s)\n", r.Path, strings.Join(failed, ", "))
+	}
+	return sb.String()
+}
+
+// FormatExpectResult renders the checks of one file with colors
+func FormatExpectResult(r *ExpectResult, labelFn, treeFn, matchFn, diffFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("expect "+filepath.Base(r.Path)))
+	fmt.Fprintf(&sb, "%s %s\n", matchFn("+++"), valueFn(r.Path))
+
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Expected:"))
+	var failed []string
+	for _, c := range r.Checks {
+		label := strings.ToUpper(c.Field)
+		if c.Field == "size" || c.Field == "mode" || c.Field == "digest" {
+			label = strings.ToUpper(c.Field[:1]) + c.Field[1:]
+		}
+		if c.Match {
+			fmt.Fprintf(&sb, "  %s %s: %s", matchFn("✓"), label, valueFn(c.Actual))
+			if c.Note != "" {
+				fmt.Fprintf(&sb, " %s", treeFn("("+c.Note+")"))
+			}
+			sb.WriteString("\n")
+			continue
+		}
+		failed = append(failed, c.Field)
+		fmt.Fprintf(&sb, "  %s %s expected: %s\n", diffFn("✗"), label, valueFn(c.Expected))
+		fmt.Fprintf(&sb, "  %s %s actual  : %s\n", diffFn("✗"), label, valueFn(c.Actual))
+	}
+
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Verdict:"))
+	if r.Match {
+		fmt.Fprintf(&sb, "  %s\n", matchFn("✓ File MATCHES the expected values"))
+	} else {
+		fmt.Fprintf(&sb, "  %s\n", diffFn(fmt.Sprintf("✗ File does NOT match (%s)", strings.Join(failed, ", "))))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLsMode(t *testing.T) {
+	tests := []struct {
+		mode os.FileMode
+		want string
+	}{
+		{0o644, "-rw-r--r--"},
+		{0o755 | os.ModeSetuid, "-rwsr-xr-x"},
+		{0o644 | os.ModeSetuid, "-rwSr--r--"},
+		{0o755 | os.ModeSetgid, "-rwxr-sr-x"},
+		{0o777 | os.ModeDir | os.ModeSticky, "drwxrwxrwt"},
+		{0o776 | os.ModeDir | os.ModeSticky, "drwxrwxrwT"},
+		{0o777 | os.ModeSymlink, "lrwxrwxrwx"},
+		{0o660 | os.ModeDevice | os.ModeCharDevice, "crw-rw----"},
+		{0o660 | os.ModeDevice, "brw-rw----"},
+	}
+	for _, tt := range tests {
+		if got := lsMode(tt.mode); got != tt.want {
+			t.Errorf("lsMode(%v) = %s, want %s", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestCheckModeSetuidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o755|os.ModeSetuid); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSetuid == 0 {
+		t.Skip("the file system dropped the setuid bit")
+	}
+
+	tests := map[string]bool{
+		"4755":       true,
+		"0755":       false,
+		"-rwsr-xr-x": true,
+		"rwsr-xr-x":  true,
+		"-rwxr-xr-x": false,
+		"urwxr-xr-x": false, // Go's notation is not what ls prints
+	}
+	for expected, want := range tests {
+		c, err := checkMode(expected, info.Mode())
+		if err != nil {
+			t.Fatalf("%s: %v", expected, err)
+		}
+		if c.Match != want {
+			t.Errorf("checkMode(%s) on %s = %v, want %v", expected, c.Actual, c.Match, want)
+		}
+	}
+
+	if _, err := checkMode("rwx", info.Mode()); err == nil {
+		t.Error("a short symbolic mode was accepted")
+	}
+}
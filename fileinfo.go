@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
 var CalculateHashesFlag bool
@@ -13,6 +17,8 @@ type FileInfo struct {
 	Path            string
 	Size            int64
 	Permissions     string
+	ModTime         time.Time
+	ChangeTime      time.Time // inode change time, where the platform provides it
 	Owner           string
 	Group           string
 	IsWritableByAll bool
@@ -20,9 +26,14 @@ type FileInfo struct {
 	Arch            string
 	OS              string
 	SymlinkChain    []string
+	Xattrs          map[string]string
 	FileType        *FileTypeInfo
 	BinaryInfo      *BinaryInfo
 	HashInfo        *HashInfo
+	Signature       *SignatureInfo
+	PathLookup      *PathLookup   // set when the file was found by searching PATH
+	Library         *LibraryMatch // set when the file was found by --lib
+	Packages        []*PackageOwner
 }
 
 // GetFileInfo retrieves comprehensive file information
@@ -43,8 +54,14 @@ func GetFileInfo(path string) (*FileInfo, error) {
 		Path:        absPath,
 		Size:        info.Size(),
 		Permissions: info.Mode().String(),
+		ModTime:     info.ModTime(),
+		Xattrs:      readXattrs(absPath),
+		PathLookup:  pathLookups[absPath],
+		Library:     libraryMatches[absPath],
 	}
 
+	fi.Packages = FindPackageOwners(systemRoot(), absPath)
+
 	// Resolve symlink chain
 	fi.SymlinkChain, err = resolveSymlinkChain(absPath)
 	if err != nil {
@@ -55,16 +72,27 @@ func GetFileInfo(path string) (*FileInfo, error) {
 	if err := getPlatformSpecificInfo(fi, info); err != nil {
 		return nil, fmt.Errorf("failed to get platform-specific info: %w", err)
 	}
+	if sysroot != "" {
+		rootOwnerNames(fi, info)
+	}
+
+	// Inside a root, the content behind a symlink is read from its target there
+	contentPath := absPath
+	if sysroot != "" {
+		if real, err := evalSymlinks(absPath); err == nil {
+			contentPath = real
+		}
+	}
 
 	// Detect file type
-	fileType, err := DetectFileType(absPath)
+	fileType, err := DetectFileType(contentPath)
 	if err == nil {
 		fi.FileType = fileType
 	}
 
 	// Analyze binary if applicable
 	if fi.FileType != nil && fi.FileType.IsBinary {
-		binaryInfo, err := AnalyzeBinary(absPath, fi.FileType)
+		binaryInfo, err := AnalyzeBinary(contentPath, fi.FileType)
 		if err == nil {
 			fi.BinaryInfo = binaryInfo
 		}
@@ -72,15 +100,45 @@ func GetFileInfo(path string) (*FileInfo, error) {
 
 	// Calculate hashes if requested
 	if CalculateHashesFlag {
-		hashInfo, err := CalculateHashes(absPath)
+		hashInfo, err := CalculateHashes(contentPath)
 		if err == nil {
 			fi.HashInfo = hashInfo
+		} else if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+
+	// Verify a detached signature, given explicitly or found next to the file
+	if sigPath := FindSignature(absPath); sigPath != "" {
+		sigInfo, err := VerifySignature(absPath, sigPath, SignatureKey)
+		if err == nil {
+			fi.Signature = sigInfo
+		} else if SignatureFile != "" {
+			return nil, fmt.Errorf("failed to verify signature: %w", err)
 		}
 	}
 
 	return fi, nil
 }
 
+// idNames caches uid/gid name lookups, which run an external command
+var idNames sync.Map
+
+// cachedName looks up the name for a uid or gid once per run
+func cachedName(kind string, id uint32, lookup func(uint32) (string, error)) (string, error) {
+	type result struct {
+		name string
+		err  error
+	}
+	key := fmt.Sprintf("%s:%d", kind, id)
+	if r, ok := idNames.Load(key); ok {
+		return r.(result).name, r.(result).err
+	}
+	name, err := lookup(id)
+	idNames.Store(key, result{name, err})
+	return name, err
+}
+
 // resolveSymlinkChain follows symlinks and returns the chain
 func resolveSymlinkChain(path string) ([]string, error) {
 	chain := []string{}
@@ -103,8 +161,12 @@ func resolveSymlinkChain(path string) ([]string, error) {
 			return nil, err
 		}
 
-		// If target is relative, make it absolute
-		if !filepath.IsAbs(target) {
+		// If target is relative, make it absolute; inside a root, absolute
+		// targets are too
+		switch {
+		case sysroot != "":
+			target = rootLinkTarget(current, target)
+		case !filepath.IsAbs(target):
 			target = filepath.Join(filepath.Dir(current), target)
 		}
 
@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 )
 
 // getPlatformSpecificInfo retrieves Linux-specific file information
@@ -25,7 +26,7 @@ func getPlatformSpecificInfo(fi *FileInfo, info os.FileInfo) error {
 	}
 
 	// Get owner name
-	ownerName, err := getUserName(stat.Uid)
+	ownerName, err := cachedName("user", stat.Uid, getUserName)
 	if err != nil {
 		fi.Owner = fmt.Sprintf("uid:%d", stat.Uid)
 	} else {
@@ -33,13 +34,15 @@ func getPlatformSpecificInfo(fi *FileInfo, info os.FileInfo) error {
 	}
 
 	// Get group name
-	groupName, err := getGroupName(stat.Gid)
+	groupName, err := cachedName("group", stat.Gid, getGroupName)
 	if err != nil {
 		fi.Group = fmt.Sprintf("gid:%d", stat.Gid)
 	} else {
 		fi.Group = groupName
 	}
 
+	fi.ChangeTime = fileChangeTime(info)
+
 	// Check if writable by all
 	mode := info.Mode()
 	fi.IsWritableByAll = mode&0002 != 0
@@ -51,6 +54,33 @@ func getPlatformSpecificInfo(fi *FileInfo, info os.FileInfo) error {
 	return nil
 }
 
+// fileIdentity returns the device, inode and link count of a file
+func fileIdentity(info os.FileInfo) (dev, ino, nlink uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	return uint64(stat.Dev), uint64(stat.Ino), uint64(stat.Nlink), true
+}
+
+// fileChangeTime returns the inode change time of a file
+func fileChangeTime(info os.FileInfo) time.Time {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(stat.Ctim.Unix())
+}
+
+// fileOwnership returns the owning uid and gid of a file
+func fileOwnership(info os.FileInfo) (uid, gid uint32, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return stat.Uid, stat.Gid, true
+}
+
 // getUserName gets the username for a given UID
 func getUserName(uid uint32) (string, error) {
 	cmd := exec.Command("id", "-un", fmt.Sprintf("%d", uid))
@@ -175,6 +175,38 @@ func FormatFileInfo(fi *FileInfo) string {
 		sb.WriteString(FormatHashInfo(fi.HashInfo, treeColor.Sprint, treeColor.Sprint, sizeColor.Sprint))
 	}
 
+	// Detached signature
+	if fi.Signature != nil {
+		sb.WriteString(labelColor.Sprint("Signature:\n"))
+		sb.WriteString(FormatSignatureInfo(fi.Signature, treeColor.Sprint, treeColor.Sprint, valueColor.Sprint, pathColor.Sprint, warnColor.Sprint))
+	}
+
+	// Every match for a command found in PATH
+	if fi.PathLookup != nil {
+		sb.WriteString(labelColor.Sprint("PATH lookup:\n"))
+		sb.WriteString(FormatPathLookup(fi.PathLookup, treeColor.Sprint, pathColor.Sprint, warnColor.Sprint, valueColor.Sprint))
+		if fi.PathLookup.Shim != nil {
+			sb.WriteString(labelColor.Sprint("Shim:\n"))
+			sb.WriteString(FormatShimInfo(fi.PathLookup.Shim, treeColor.Sprint, pathColor.Sprint, warnColor.Sprint, valueColor.Sprint))
+		}
+	}
+
+	// How --lib found a library, and whether the loader would use it
+	if fi.Library != nil {
+		sb.WriteString(labelColor.Sprint("Library:\n"))
+		sb.WriteString(FormatLibraryMatch(fi.Library, treeColor.Sprint, pathColor.Sprint, warnColor.Sprint, valueColor.Sprint))
+		for _, pc := range fi.Library.Packages {
+			sb.WriteString(labelColor.Sprint("pkg-config:\n"))
+			sb.WriteString(FormatPkgConfig(pc, treeColor.Sprint, pathColor.Sprint, warnColor.Sprint, valueColor.Sprint))
+		}
+	}
+
+	// The OS package that installed the file
+	for _, o := range fi.Packages {
+		sb.WriteString(labelColor.Sprint("Package:\n"))
+		sb.WriteString(FormatPackageOwner(o, treeColor.Sprint, pathColor.Sprint, warnColor.Sprint, valueColor.Sprint))
+	}
+
 	// Symlink chain (if exists)
 	if len(fi.SymlinkChain) > 0 {
 		sb.WriteString(labelColor.Sprint("Symlink chain:\n"))
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Context-triggered piecewise hashing, compatible with ssdeep's spamsum format
+// ("blocksize:hash1:hash2"). Two files with similar content produce similar
+// hash strings, which FuzzyCompare scores from 0 (unrelated) to 100.
+
+const (
+	spamsumLength  = 64
+	minBlockSize   = 3
+	rollingWindow  = 7
+	fuzzyHashInit  = 0x28021967
+	fuzzyHashPrime = 0x01000193
+	fuzzyB64       = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+)
+
+// rollingHash is the Adler-style rolling hash used to pick chunk boundaries
+type rollingHash struct {
+	h1, h2, h3 uint32
+	n          uint32
+	window     [rollingWindow]byte
+}
+
+func (r *rollingHash) roll(c byte) uint32 {
+	r.h2 -= r.h1
+	r.h2 += rollingWindow * uint32(c)
+	r.h1 += uint32(c)
+	r.h1 -= uint32(r.window[r.n%rollingWindow])
+	r.window[r.n%rollingWindow] = c
+	r.n++
+	r.h3 <<= 5
+	r.h3 ^= uint32(c)
+	return r.h1 + r.h2 + r.h3
+}
+
+// fuzzyRun is a piecewise hash in progress. Levels that last restarted at
+// the same byte share one, so content without chunk boundaries (long runs of
+// zeros) costs a single update per byte instead of one per level.
+type fuzzyRun struct {
+	h    uint32
+	refs int
+}
+
+// fuzzyLevel is the signature state for one candidate block size
+type fuzzyLevel struct {
+	blockSize uint32
+	h1, h2    *fuzzyRun
+	sig1      []byte
+	sig2      []byte
+}
+
+// fuzzyHasher computes spamsum signatures for every candidate block size in
+// one pass, as ssdeep does, so repetitive content never needs a re-read
+type fuzzyHasher struct {
+	roll   rollingHash
+	levels []*fuzzyLevel // ascending block sizes, from minBlockSize
+	first  int           // levels below this can no longer be picked
+	runs   []*fuzzyRun
+	last   uint32
+}
+
+// newFuzzyHasher tracks block sizes from minBlockSize up to maxBlockSize, the
+// size expected to fill the signature
+func newFuzzyHasher(maxBlockSize uint32) *fuzzyHasher {
+	start := &fuzzyRun{h: fuzzyHashInit}
+	f := &fuzzyHasher{runs: []*fuzzyRun{start}}
+	for bs := uint32(minBlockSize); ; bs *= 2 {
+		f.levels = append(f.levels, &fuzzyLevel{
+			blockSize: bs,
+			h1:        start,
+			h2:        start,
+			sig1:      make([]byte, 0, spamsumLength),
+			sig2:      make([]byte, 0, spamsumLength/2),
+		})
+		start.refs += 2
+		if bs >= maxBlockSize {
+			break
+		}
+	}
+	return f
+}
+
+// restart points *r at a fresh piecewise hash, shared with any other level
+// restarting at the same byte
+func (f *fuzzyHasher) restart(r **fuzzyRun, fresh **fuzzyRun) {
+	if *fresh == nil {
+		*fresh = &fuzzyRun{h: fuzzyHashInit}
+		f.runs = append(f.runs, *fresh)
+	}
+	f.release(*r)
+	(*fresh).refs++
+	*r = *fresh
+}
+
+// release drops a level's reference to a run, forgetting the run once unused
+func (f *fuzzyHasher) release(r *fuzzyRun) {
+	r.refs--
+	if r.refs > 0 {
+		return
+	}
+	for i, run := range f.runs {
+		if run == r {
+			f.runs[i] = f.runs[len(f.runs)-1]
+			f.runs = f.runs[:len(f.runs)-1]
+			return
+		}
+	}
+}
+
+// Write implements io.Writer so the hasher can sit in an io.MultiWriter
+func (f *fuzzyHasher) Write(b []byte) (int, error) {
+	for _, c := range b {
+		h := f.roll.roll(c)
+		for _, r := range f.runs {
+			r.h = (r.h * fuzzyHashPrime) ^ uint32(c)
+		}
+
+		// Block sizes double, so a boundary for one level is a boundary for
+		// every smaller one; stop at the first level without one
+		var fresh *fuzzyRun
+		drop := 0
+		levels := f.levels[f.first:]
+		for i, l := range levels {
+			bs := l.blockSize
+			if h%bs != bs-1 {
+				break
+			}
+			// Once the signature is full the last character keeps being overwritten
+			if len(l.sig1) < spamsumLength-1 {
+				l.sig1 = append(l.sig1, fuzzyB64[l.h1.h%64])
+				f.restart(&l.h1, &fresh)
+			} else {
+				l.sig1 = append(l.sig1[:spamsumLength-1], fuzzyB64[l.h1.h%64])
+			}
+			if h%(bs*2) == bs*2-1 {
+				if len(l.sig2) < spamsumLength/2-1 {
+					l.sig2 = append(l.sig2, fuzzyB64[l.h2.h%64])
+					f.restart(&l.h2, &fresh)
+				} else {
+					l.sig2 = append(l.sig2[:spamsumLength/2-1], fuzzyB64[l.h2.h%64])
+				}
+			}
+			// A long enough signature here is preferred over any smaller block size
+			if len(l.sig1) >= spamsumLength/2 {
+				drop = i
+			}
+		}
+		for _, l := range levels[:drop] {
+			f.release(l.h1)
+			f.release(l.h2)
+		}
+		f.first += drop
+		f.last = h
+	}
+	return len(b), nil
+}
+
+// Sum returns the signature in "blocksize:hash1:hash2" form, using the largest
+// block size whose signature is long enough to be useful
+func (f *fuzzyHasher) Sum() string {
+	i := len(f.levels) - 1
+	for i > 0 && len(f.levels[i].sig1) < spamsumLength/2 {
+		i--
+	}
+	l := f.levels[i]
+	sig1, sig2 := string(l.sig1), string(l.sig2)
+	if f.last != 0 {
+		// The trailing partial chunk replaces an overwritten final character
+		sig1 = string(l.sig1[:min(len(l.sig1), spamsumLength-1)]) + string(fuzzyB64[l.h1.h%64])
+		sig2 = string(l.sig2[:min(len(l.sig2), spamsumLength/2-1)]) + string(fuzzyB64[l.h2.h%64])
+	}
+	return fmt.Sprintf("%d:%s:%s", l.blockSize, sig1, sig2)
+}
+
+// fuzzyBlockSize picks the largest block size worth trying for a file of the
+// given size
+func fuzzyBlockSize(size int64) uint32 {
+	bs := uint32(minBlockSize)
+	for int64(bs)*spamsumLength < size {
+		bs *= 2
+	}
+	return bs
+}
+
+// FuzzyCompare scores the similarity of two fuzzy hashes from 0 to 100
+func FuzzyCompare(a, b string) (int, error) {
+	bs1, a1, a2, err := parseFuzzyHash(a)
+	if err != nil {
+		return 0, err
+	}
+	bs2, b1, b2, err := parseFuzzyHash(b)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only signatures with the same or adjacent block sizes are comparable
+	if bs1 != bs2 && bs1 != bs2*2 && bs2 != bs1*2 {
+		return 0, nil
+	}
+
+	a1, a2 = eliminateSequences(a1), eliminateSequences(a2)
+	b1, b2 = eliminateSequences(b1), eliminateSequences(b2)
+
+	if bs1 == bs2 && a1 == b1 {
+		return 100, nil
+	}
+
+	switch {
+	case bs1 == bs2:
+		return max(scoreStrings(a1, b1, bs1), scoreStrings(a2, b2, bs1*2)), nil
+	case bs1 == bs2*2:
+		return scoreStrings(a1, b2, bs1), nil
+	default:
+		return scoreStrings(a2, b1, bs2), nil
+	}
+}
+
+// parseFuzzyHash splits a "blocksize:hash1:hash2" signature
+func parseFuzzyHash(s string) (uint32, string, string, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 {
+		return 0, "", "", fmt.Errorf("invalid fuzzy hash '%s'", s)
+	}
+	bs, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("invalid fuzzy hash block size '%s'", parts[0])
+	}
+	return uint32(bs), parts[1], parts[2], nil
+}
+
+// eliminateSequences collapses runs of more than three identical characters,
+// which carry little information and would inflate scores
+func eliminateSequences(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if i >= 3 && s[i] == s[i-1] && s[i] == s[i-2] && s[i] == s[i-3] {
+			continue
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
+
+// scoreStrings scores two signature strings generated with the same block size
+func scoreStrings(s1, s2 string, blockSize uint32) int {
+	if len(s1) > spamsumLength || len(s2) > spamsumLength {
+		return 0
+	}
+	if !hasCommonSubstring(s1, s2) {
+		return 0
+	}
+
+	// Scale the edit distance to 0-100, where 0 means identical
+	score := editDistance(s1, s2)
+	score = score * spamsumLength / (len(s1) + len(s2))
+	score = 100 * score / spamsumLength
+	if score >= 100 {
+		return 0
+	}
+	score = 100 - score
+
+	// Small block sizes can't justify very high scores for short signatures
+	if blockSize >= (99+rollingWindow)/rollingWindow*minBlockSize {
+		return score
+	}
+	limit := int(blockSize) / minBlockSize * min(len(s1), len(s2))
+	return min(score, limit)
+}
+
+// hasCommonSubstring reports whether the strings share a run of rollingWindow characters
+func hasCommonSubstring(s1, s2 string) bool {
+	if len(s1) < rollingWindow || len(s2) < rollingWindow {
+		return false
+	}
+	seen := make(map[string]bool, len(s1))
+	for i := 0; i+rollingWindow <= len(s1); i++ {
+		seen[s1[i:i+rollingWindow]] = true
+	}
+	for i := 0; i+rollingWindow <= len(s2); i++ {
+		if seen[s2[i:i+rollingWindow]] {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance is a weighted Levenshtein distance (insert/delete 1, replace 2)
+func editDistance(s1, s2 string) int {
+	prev := make([]int, len(s2)+1)
+	cur := make([]int, len(s2)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s1); i++ {
+		cur[0] = i
+		for j := 1; j <= len(s2); j++ {
+			replace := prev[j-1]
+			if s1[i-1] != s2[j-1] {
+				replace += 2
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, replace)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(s2)]
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// fuzzyHash hashes data in one write, the way calculateHashes sizes it
+func fuzzyHash(data []byte) string {
+	f := newFuzzyHasher(fuzzyBlockSize(int64(len(data))))
+	_, _ = f.Write(data)
+	return f.Sum()
+}
+
+// randomText returns n bytes of seeded pseudo-random text
+func randomText(seed int64, n int) []byte {
+	r := rand.New(rand.NewSource(seed))
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte('a' + r.Intn(26))
+	}
+	return data
+}
+
+func TestFuzzyHashEmpty(t *testing.T) {
+	if got := fuzzyHash(nil); got != "3::" {
+		t.Errorf("hash of empty input = %q, want 3::", got)
+	}
+}
+
+func TestFuzzyHashChunkedWrites(t *testing.T) {
+	data := randomText(1, 200000)
+	want := fuzzyHash(data)
+
+	f := newFuzzyHasher(fuzzyBlockSize(int64(len(data))))
+	for i := 0; i < len(data); i += 4093 {
+		_, _ = f.Write(data[i:min(i+4093, len(data))])
+	}
+	if got := f.Sum(); got != want {
+		t.Errorf("chunked writes gave %s, want %s", got, want)
+	}
+
+	bs, sig1, sig2, err := parseFuzzyHash(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs < minBlockSize || len(sig1) < spamsumLength/2 || len(sig1) > spamsumLength || len(sig2) > spamsumLength/2 {
+		t.Errorf("unexpected signature shape %s", want)
+	}
+}
+
+func TestFuzzyCompare(t *testing.T) {
+	data := randomText(2, 100000)
+	edited := append([]byte(nil), data...)
+	copy(edited[50000:], "a small edit in the middle of the file")
+	unrelated := randomText(3, 100000)
+
+	tests := []struct {
+		name    string
+		a, b    []byte
+		atLeast int
+		atMost  int
+	}{
+		{"identical", data, data, 100, 100},
+		{"small edit", data, edited, 80, 99},
+		{"unrelated", data, unrelated, 0, 0},
+		{"truncated", data[:90000], data, 1, 99}, // adjacent block sizes
+	}
+	for _, tt := range tests {
+		got, err := FuzzyCompare(fuzzyHash(tt.a), fuzzyHash(tt.b))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got < tt.atLeast || got > tt.atMost {
+			t.Errorf("%s: score %d, want %d-%d", tt.name, got, tt.atLeast, tt.atMost)
+		}
+	}
+}
+
+func TestFuzzyCompareBlockSizes(t *testing.T) {
+	sig := "HxAAjsKDdj3JCjGHDrGY8Wdd/NZM7aaa"
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"96:" + sig + ":x", "96:" + sig + ":y", 100},
+		{"96:" + sig + ":x", "384:" + sig + ":x", 0}, // too far apart
+		{"96:x:" + sig, "192:" + sig + ":y", 100},    // adjacent sizes compare across halves
+	}
+	for _, tt := range tests {
+		got, err := FuzzyCompare(tt.a, tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("FuzzyCompare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyCompareInvalid(t *testing.T) {
+	for _, hash := range []string{"", "96:abc", "x:abc:def", "-3:abc:def"} {
+		if _, err := FuzzyCompare(hash, "3::"); err == nil {
+			t.Errorf("FuzzyCompare accepted %q", hash)
+		}
+	}
+}
+
+func TestEliminateSequences(t *testing.T) {
+	tests := map[string]string{
+		"":            "",
+		"abc":         "abc",
+		"aaab":        "aaab",
+		"aaaaaab":     "aaab",
+		"xbbbbbbcccc": "xbbbccc",
+	}
+	for in, want := range tests {
+		if got := eliminateSequences(in); got != want {
+			t.Errorf("eliminateSequences(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"abc", "abc", 0},
+		{"abc", "abd", 2}, // a replacement costs 2
+		{"abc", "abxc", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestHasCommonSubstring(t *testing.T) {
+	if !hasCommonSubstring("xxABCDEFGyy", "zzABCDEFG") {
+		t.Error("a shared 7-character run was missed")
+	}
+	if hasCommonSubstring("ABCDEFx", "ABCDEFy") {
+		t.Error("a 6-character run counted as common")
+	}
+	if hasCommonSubstring(strings.Repeat("a", 6), strings.Repeat("a", 6)) {
+		t.Error("strings shorter than the window share nothing")
+	}
+}
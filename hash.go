@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -14,150 +17,402 @@ import (
 
 // HashInfo contains file hash information
 type HashInfo struct {
-	MD5    string
-	SHA256 string
-	SHA512 string
+	MD5       string
+	SHA1      string
+	SHA256    string
+	SHA384    string
+	SHA512    string
+	GitSHA1   string // git blob object id (sha1 object format)
+	GitSHA256 string // git blob object id (sha256 object format)
+	SRI       string // Subresource Integrity string, e.g. sha384-<base64>
+	OCIDigest string // OCI/Docker content digest, e.g. sha256:<hex>
+	SSDeep    string // context-triggered piecewise (fuzzy) hash
 }
 
-// CalculateHashes calculates MD5, SHA256, and SHA512 hashes for a file
+// FuzzyHashFlag adds the ssdeep fuzzy hash to --hash output. --diff always
+// computes it for the similarity score.
+var FuzzyHashFlag bool
+
+// CalculateHashes calculates all supported hashes and digests for a file
 func CalculateHashes(path string) (*HashInfo, error) {
+	return CalculateHashesContext(HashContext, path)
+}
+
+// CalculateHashesContext calculates hashes for a file, stopping early if ctx is cancelled.
+// Progress is reported on stderr when enabled and stderr is a terminal.
+func CalculateHashesContext(ctx context.Context, path string) (*HashInfo, error) {
+	return calculateHashes(ctx, path, FuzzyHashFlag)
+}
+
+// calculateHashes hashes a file in one pass, including the fuzzy hash when
+// fuzzy is set
+func calculateHashes(ctx context.Context, path string, fuzzy bool) (*HashInfo, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = file.Close() }()
 
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
 	md5Hash := md5.New()
+	sha1Hash := sha1.New()
 	sha256Hash := sha256.New()
+	sha384Hash := sha512.New384()
 	sha512Hash := sha512.New()
 
+	// Git hashes the object header before the content
+	gitSHA1Hash := sha1.New()
+	gitSHA256Hash := sha256.New()
+	header := gitBlobHeader(stat.Size())
+	_, _ = gitSHA1Hash.Write(header)
+	_, _ = gitSHA256Hash.Write(header)
+
 	// Use MultiWriter to calculate all hashes in one pass
-	multiWriter := io.MultiWriter(md5Hash, sha256Hash, sha512Hash)
+	writers := []io.Writer{md5Hash, sha1Hash, sha256Hash, sha384Hash, sha512Hash, gitSHA1Hash, gitSHA256Hash}
 
-	if _, err := io.Copy(multiWriter, file); err != nil {
+	var fuzzyHasher *fuzzyHasher
+	if fuzzy {
+		fuzzyHasher = newFuzzyHasher(fuzzyBlockSize(stat.Size()))
+		writers = append(writers, fuzzyHasher)
+	}
+
+	var progress *ProgressReporter
+	if progressEnabled() {
+		progress = NewProgressReporter(progressOutput, path, stat.Size())
+		writers = append(writers, progress)
+	}
+	multiWriter := io.MultiWriter(writers...)
+
+	_, err = io.Copy(multiWriter, &contextReader{ctx: ctx, r: file})
+	if progress != nil {
+		progress.Finish(err)
+	}
+	if err != nil {
 		return nil, err
 	}
 
+	var fuzzyHash string
+	if fuzzyHasher != nil {
+		fuzzyHash = fuzzyHasher.Sum()
+	}
+
+	sha256Sum := sha256Hash.Sum(nil)
+	sha384Sum := sha384Hash.Sum(nil)
+
 	return &HashInfo{
-		MD5:    hex.EncodeToString(md5Hash.Sum(nil)),
-		SHA256: hex.EncodeToString(sha256Hash.Sum(nil)),
-		SHA512: hex.EncodeToString(sha512Hash.Sum(nil)),
+		MD5:       hex.EncodeToString(md5Hash.Sum(nil)),
+		SHA1:      hex.EncodeToString(sha1Hash.Sum(nil)),
+		SHA256:    hex.EncodeToString(sha256Sum),
+		SHA384:    hex.EncodeToString(sha384Sum),
+		SHA512:    hex.EncodeToString(sha512Hash.Sum(nil)),
+		GitSHA1:   hex.EncodeToString(gitSHA1Hash.Sum(nil)),
+		GitSHA256: hex.EncodeToString(gitSHA256Hash.Sum(nil)),
+		SRI:       sriString("sha384", sha384Sum),
+		OCIDigest: ociDigest("sha256", sha256Sum),
+		SSDeep:    fuzzyHash,
 	}, nil
 }
 
-// FormatHashInfo formats hash information for display with colors
+// FormatHashInfo formats hash information for display with colors.
+// Plain digests use HashEncoding; git ids, SRI and OCI digests keep their native form.
 func FormatHashInfo(info *HashInfo, labelFn, treeFn, valueFn func(a ...interface{}) string) string {
 	var sb strings.Builder
 
-	fmt.Fprintf(&sb, "  %s %s %s\n",
-		treeFn("├─"),
-		labelFn("MD5    :"),
-		valueFn(info.MD5))
-	fmt.Fprintf(&sb, "  %s %s %s\n",
-		treeFn("├─"),
-		labelFn("SHA256 :"),
-		valueFn(info.SHA256))
-	fmt.Fprintf(&sb, "  %s %s %s\n",
-		treeFn("╰─"),
-		labelFn("SHA512 :"),
-		valueFn(info.SHA512))
+	rows := []struct {
+		label string
+		value string
+	}{
+		{"MD5       :", encodeDigest(info.MD5, "md5", HashEncoding)},
+		{"SHA1      :", encodeDigest(info.SHA1, "sha1", HashEncoding)},
+		{"SHA256    :", encodeDigest(info.SHA256, "sha256", HashEncoding)},
+		{"SHA384    :", encodeDigest(info.SHA384, "sha384", HashEncoding)},
+		{"SHA512    :", encodeDigest(info.SHA512, "sha512", HashEncoding)},
+		{"Git SHA1  :", info.GitSHA1},
+		{"Git SHA256:", info.GitSHA256},
+		{"SRI       :", info.SRI},
+		{"OCI       :", info.OCIDigest},
+	}
+	if info.SSDeep != "" {
+		rows = append(rows, struct {
+			label string
+			value string
+		}{"SSDeep    :", info.SSDeep})
+	}
+
+	for i, row := range rows {
+		branch := "├─"
+		if i == len(rows)-1 {
+			branch = "╰─"
+		}
+		fmt.Fprintf(&sb, "  %s %s %s\n",
+			treeFn(branch),
+			labelFn(row.label),
+			valueFn(row.value))
+	}
 
 	return sb.String()
 }
 
-// CompareFiles compares two files and returns differences with git-like formatting
-func CompareFiles(path1, path2 string, labelFn, treeFn, matchFn, diffFn, valueFn func(a ...interface{}) string) (string, error) {
+// CompareFiles compares two files (or two directory trees) and returns differences with
+// git-like formatting, along with a summary of the verdict
+func CompareFiles(path1, path2 string, labelFn, treeFn, matchFn, diffFn, valueFn func(a ...interface{}) string) (string, *DiffSummary, error) {
+	if err := checkIgnoreFields(DiffOpts.Ignore); err != nil {
+		return "", nil, err
+	}
+
 	// Get file info for both
 	info1, err := os.Stat(path1)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	info2, err := os.Stat(path2)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+
+	// Two directories are compared recursively
+	if info1.IsDir() && info2.IsDir() {
+		return compareDirectoriesOutput(path1, path2, labelFn, treeFn, matchFn, diffFn, valueFn)
+	}
+	if info1.IsDir() || info2.IsDir() {
+		return "", nil, fmt.Errorf("cannot compare a directory with a file")
 	}
 
+	// Everything finfo knows about each file
+	fi1, err := GetFileInfo(path1)
+	if err != nil {
+		return "", nil, err
+	}
+	fi2, err := GetFileInfo(path2)
+	if err != nil {
+		return "", nil, err
+	}
+	ignore := DiffOpts.Ignore
+	var metaDiffs []string
+	summary := &DiffSummary{Path1: path1, Path2: path2, Kind: "file"}
+
 	var sb strings.Builder
 	fmt.Fprintf(&sb, "\n%s\n", labelFn("diff "+filepath.Base(path1)+" "+filepath.Base(path2)))
 	fmt.Fprintf(&sb, "%s %s\n", diffFn("---"), valueFn(path1))
-	fmt.Fprintf(&sb, "%s %s\n\n", matchFn("+++"), valueFn(path2))
+	fmt.Fprintf(&sb, "%s %s\n", matchFn("+++"), valueFn(path2))
 
 	// Compare sizes
-	sb.WriteString(labelFn("Size:\n"))
-	if info1.Size() == info2.Size() {
-		fmt.Fprintf(&sb, "  %s Both files are %s\n",
-			matchFn("✓"),
-			valueFn(fmt.Sprintf("%d bytes", info1.Size())))
-	} else {
-		fmt.Fprintf(&sb, "  %s File 1: %s\n", diffFn("✗"), valueFn(fmt.Sprintf("%d bytes", info1.Size())))
-		fmt.Fprintf(&sb, "  %s File 2: %s\n", diffFn("✗"), valueFn(fmt.Sprintf("%d bytes", info2.Size())))
-		diff := info1.Size() - info2.Size()
-		if diff > 0 {
-			fmt.Fprintf(&sb, "  %s\n", diffFn(fmt.Sprintf("Δ File 1 is %d bytes larger", diff)))
+	if !ignoresField(ignore, "size") {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn("Size:"))
+		if info1.Size() == info2.Size() {
+			fmt.Fprintf(&sb, "  %s Both files are %s\n",
+				matchFn("✓"),
+				valueFn(fmt.Sprintf("%d bytes", info1.Size())))
 		} else {
-			fmt.Fprintf(&sb, "  %s\n", diffFn(fmt.Sprintf("Δ File 2 is %d bytes larger", -diff)))
+			fmt.Fprintf(&sb, "  %s File 1: %s\n", diffFn("✗"), valueFn(fmt.Sprintf("%d bytes", info1.Size())))
+			fmt.Fprintf(&sb, "  %s File 2: %s\n", diffFn("✗"), valueFn(fmt.Sprintf("%d bytes", info2.Size())))
+			summary.Differences = append(summary.Differences, "size")
+			diff := info1.Size() - info2.Size()
+			if diff > 0 {
+				fmt.Fprintf(&sb, "  %s\n", diffFn(fmt.Sprintf("Δ File 1 is %d bytes larger", diff)))
+			} else {
+				fmt.Fprintf(&sb, "  %s\n", diffFn(fmt.Sprintf("Δ File 2 is %d bytes larger", -diff)))
+			}
 		}
 	}
 
 	// Compare permissions
-	fmt.Fprintf(&sb, "\n%s\n", labelFn("Permissions:"))
-	if info1.Mode() == info2.Mode() {
-		fmt.Fprintf(&sb, "  %s Both files have %s\n", matchFn("✓"), valueFn(info1.Mode().String()))
-	} else {
-		fmt.Fprintf(&sb, "  %s File 1: %s\n", diffFn("✗"), valueFn(info1.Mode().String()))
-		fmt.Fprintf(&sb, "  %s File 2: %s\n", diffFn("✗"), valueFn(info2.Mode().String()))
+	if !ignoresField(ignore, "mode") {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn("Permissions:"))
+		if info1.Mode() == info2.Mode() {
+			fmt.Fprintf(&sb, "  %s Both files have %s\n", matchFn("✓"), valueFn(info1.Mode().String()))
+		} else {
+			fmt.Fprintf(&sb, "  %s File 1: %s\n", diffFn("✗"), valueFn(info1.Mode().String()))
+			fmt.Fprintf(&sb, "  %s File 2: %s\n", diffFn("✗"), valueFn(info2.Mode().String()))
+			metaDiffs = append(metaDiffs, "mode")
+		}
 	}
 
 	// Compare modification times
-	fmt.Fprintf(&sb, "\n%s\n", labelFn("Modified:"))
-	if info1.ModTime().Equal(info2.ModTime()) {
-		fmt.Fprintf(&sb, "  %s Both at %s\n",
-			matchFn("✓"),
-			valueFn(info1.ModTime().Format("2006-01-02 15:04:05")))
-	} else {
-		fmt.Fprintf(&sb, "  %s File 1: %s\n", diffFn("✗"), valueFn(info1.ModTime().Format("2006-01-02 15:04:05")))
-		fmt.Fprintf(&sb, "  %s File 2: %s\n", diffFn("✗"), valueFn(info2.ModTime().Format("2006-01-02 15:04:05")))
+	if !ignoresField(ignore, "mtime") {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn("Modified:"))
+		if info1.ModTime().Equal(info2.ModTime()) {
+			fmt.Fprintf(&sb, "  %s Both at %s\n",
+				matchFn("✓"),
+				valueFn(info1.ModTime().Format("2006-01-02 15:04:05")))
+		} else {
+			fmt.Fprintf(&sb, "  %s File 1: %s\n", diffFn("✗"), valueFn(info1.ModTime().Format("2006-01-02 15:04:05")))
+			fmt.Fprintf(&sb, "  %s File 2: %s\n", diffFn("✗"), valueFn(info2.ModTime().Format("2006-01-02 15:04:05")))
+			metaDiffs = append(metaDiffs, "mtime")
+		}
+	}
+
+	// Compare owner, type, libraries, signature and the rest of FileInfo
+	if changes := CompareMetadata(fi1, fi2, ignore); len(changes) > 0 {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn("Metadata:"))
+		sb.WriteString(FormatMetadataDiff(changes, treeFn, matchFn, diffFn, valueFn))
+		metaDiffs = append(metaDiffs, differingFields(changes)...)
 	}
 
 	// Calculate and compare hashes
 	fmt.Fprintf(&sb, "\n%s\n", labelFn("Checksums:"))
-	hash1, err1 := CalculateHashes(path1)
-	hash2, err2 := CalculateHashes(path2)
+	hash1, err1 := fi1.HashInfo, error(nil)
+	if hash1 == nil || hash1.SSDeep == "" {
+		hash1, err1 = calculateHashes(HashContext, path1, true)
+	}
+	hash2, err2 := fi2.HashInfo, error(nil)
+	if hash2 == nil || hash2.SSDeep == "" {
+		hash2, err2 = calculateHashes(HashContext, path2, true)
+	}
+	if err1 != nil {
+		return "", nil, err1
+	}
+	if err2 != nil {
+		return "", nil, err2
+	}
 
-	if err1 == nil && err2 == nil {
-		if hash1.MD5 == hash2.MD5 {
-			fmt.Fprintf(&sb, "  %s MD5: %s\n", matchFn("✓"), valueFn(hash1.MD5))
+	if hash1.MD5 == hash2.MD5 {
+		fmt.Fprintf(&sb, "  %s MD5: %s\n", matchFn("✓"), valueFn(hash1.MD5))
+	} else {
+		fmt.Fprintf(&sb, "  %s MD5 File 1: %s\n", diffFn("✗"), valueFn(hash1.MD5))
+		fmt.Fprintf(&sb, "  %s MD5 File 2: %s\n", diffFn("✗"), valueFn(hash2.MD5))
+	}
+
+	if hash1.SHA256 == hash2.SHA256 {
+		fmt.Fprintf(&sb, "  %s SHA256: %s\n", matchFn("✓"), valueFn(hash1.SHA256))
+	} else {
+		fmt.Fprintf(&sb, "  %s SHA256 File 1: %s\n", diffFn("✗"), valueFn(hash1.SHA256))
+		fmt.Fprintf(&sb, "  %s SHA256 File 2: %s\n", diffFn("✗"), valueFn(hash2.SHA256))
+	}
+
+	if hash1.SHA512 == hash2.SHA512 {
+		fmt.Fprintf(&sb, "  %s SHA512: %s\n", matchFn("✓"), valueFn(hash1.SHA512))
+	} else {
+		fmt.Fprintf(&sb, "  %s SHA512 File 1: %s\n", diffFn("✗"), valueFn(hash1.SHA512))
+		fmt.Fprintf(&sb, "  %s SHA512 File 2: %s\n", diffFn("✗"), valueFn(hash2.SHA512))
+	}
+
+	// Fuzzy hash similarity, for near-duplicates
+	similarity, simErr := FuzzyCompare(hash1.SSDeep, hash2.SSDeep)
+	if hash1.SHA256 == hash2.SHA256 {
+		similarity, simErr = 100, nil
+	}
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Similarity:"))
+	if simErr != nil {
+		fmt.Fprintf(&sb, "  %s %s\n", diffFn("✗"), valueFn(simErr.Error()))
+	} else {
+		if hash1.SSDeep == hash2.SSDeep {
+			fmt.Fprintf(&sb, "  %s SSDeep: %s\n", matchFn("✓"), valueFn(hash1.SSDeep))
 		} else {
-			fmt.Fprintf(&sb, "  %s MD5 File 1: %s\n", diffFn("✗"), valueFn(hash1.MD5))
-			fmt.Fprintf(&sb, "  %s MD5 File 2: %s\n", diffFn("✗"), valueFn(hash2.MD5))
+			fmt.Fprintf(&sb, "  %s SSDeep File 1: %s\n", diffFn("✗"), valueFn(hash1.SSDeep))
+			fmt.Fprintf(&sb, "  %s SSDeep File 2: %s\n", diffFn("✗"), valueFn(hash2.SSDeep))
+		}
+		scoreFn := diffFn
+		if similarity >= 50 {
+			scoreFn = matchFn
 		}
+		fmt.Fprintf(&sb, "  %s Score: %s\n", treeFn("╰─"), scoreFn(fmt.Sprintf("%d/100", similarity)))
+	}
 
-		if hash1.SHA256 == hash2.SHA256 {
-			fmt.Fprintf(&sb, "  %s SHA256: %s\n", matchFn("✓"), valueFn(hash1.SHA256))
-		} else {
-			fmt.Fprintf(&sb, "  %s SHA256 File 1: %s\n", diffFn("✗"), valueFn(hash1.SHA256))
-			fmt.Fprintf(&sb, "  %s SHA256 File 2: %s\n", diffFn("✗"), valueFn(hash2.SHA256))
+	// Line-level diff when both files are text, byte ranges otherwise
+	details := fmt.Sprintf("%d%% similar", similarity)
+	equivalent, archiveSame := false, false
+	text := bothText(path1, path2)
+
+	// Archives are compared entry by entry instead of byte by byte
+	archived := false
+	if format1, format2 := detectArchive(path1), detectArchive(path2); hash1.SHA256 != hash2.SHA256 && format1 != "" && format2 != "" {
+		if ad, err := CompareArchives(path1, format1, path2, format2, ignore); err == nil {
+			archived = true
+			fmt.Fprintf(&sb, "\n%s\n", labelFn("Archive:"))
+			sb.WriteString(FormatArchiveDiff(ad, labelFn, treeFn, matchFn, diffFn, valueFn))
+			archiveSame = !ad.Differs()
+			if archiveSame {
+				details += "; same archive entries, different encoding"
+			} else {
+				details += fmt.Sprintf("; %d entries changed, %d added, %d removed", len(ad.Changed), len(ad.Added), len(ad.Removed))
+			}
+		} else if errors.Is(err, context.Canceled) {
+			return "", nil, err
 		}
+	}
 
-		if hash1.SHA512 == hash2.SHA512 {
-			fmt.Fprintf(&sb, "  %s SHA512: %s\n", matchFn("✓"), valueFn(hash1.SHA512))
-		} else {
-			fmt.Fprintf(&sb, "  %s SHA512 File 1: %s\n", diffFn("✗"), valueFn(hash1.SHA512))
-			fmt.Fprintf(&sb, "  %s SHA512 File 2: %s\n", diffFn("✗"), valueFn(hash2.SHA512))
+	if hash1.SHA256 != hash2.SHA256 && !text && !archived {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn("Bytes:"))
+		if bd, err := DiffBinaryFiles(path1, path2, DiffOpts.IgnoreVolatile); err == nil {
+			sb.WriteString(FormatByteDiff(bd, path1, path2, labelFn, treeFn, matchFn, diffFn, valueFn))
+			if bd.DiffBytes > 0 {
+				details += fmt.Sprintf("; first difference at 0x%x, %.2f%% of bytes changed", bd.FirstDiff, bd.ChangePercent)
+			}
+			equivalent = bd.DiffBytes == 0
 		}
 
-		// Overall verdict
-		fmt.Fprintf(&sb, "\n%s\n", labelFn("Verdict:"))
-		if hash1.SHA256 == hash2.SHA256 && info1.Size() == info2.Size() {
-			fmt.Fprintf(&sb, "  %s\n", matchFn("✓ Files are IDENTICAL (same content)"))
-		} else if hash1.SHA256 != hash2.SHA256 && info1.Size() == info2.Size() {
-			fmt.Fprintf(&sb, "  %s\n", diffFn("✗ Files are DIFFERENT (same size, different content)"))
-		} else {
-			fmt.Fprintf(&sb, "  %s\n", diffFn("✗ Files are DIFFERENT"))
+		// Structural comparison of ELF / Mach-O files
+		bs1, err1 := ReadBinaryStructure(path1)
+		bs2, err2 := ReadBinaryStructure(path2)
+		if err1 == nil && err2 == nil {
+			sb.WriteString("\n")
+			sb.WriteString(FormatBinaryStructureDiff(bs1, bs2, labelFn, treeFn, matchFn, diffFn, valueFn))
+			summary.ABIBreaks = abiBreaks(bs1, bs2)
+		}
+	}
+	if hash1.SHA256 != hash2.SHA256 && text {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn("Content:"))
+		if info1.Size() > DiffOpts.MaxTextSize || info2.Size() > DiffOpts.MaxTextSize {
+			fmt.Fprintf(&sb, "  %s %s\n", diffFn("✗"),
+				valueFn(fmt.Sprintf("Line diff skipped (larger than %s, see --max-diff-size)", formatBytes(DiffOpts.MaxTextSize))))
+		} else if td, err := DiffTextFiles(path1, path2, DiffOpts.ContextLines); err == nil {
+			sb.WriteString(FormatTextDiff(td, DiffOpts.WordDiff, labelFn, matchFn, diffFn, valueFn))
+			details += fmt.Sprintf("; %d hunk(s), +%d -%d lines", len(td.Hunks), td.Added, td.Removed)
 		}
 	}
 
+	// Overall verdict
+	summary.Differences = append(summary.Differences, metaDiffs...)
+	if hash1.SHA256 != hash2.SHA256 {
+		summary.Differences = append(summary.Differences, "content")
+	}
+	if simErr == nil {
+		summary.Similarity = &similarity
+	}
+	// Equal digests imply equal sizes, so the verdict rests on content and the
+	// metadata fields that weren't ignored
+	sameContent := hash1.SHA256 == hash2.SHA256
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Verdict:"))
+	if sameContent && len(metaDiffs) > 0 {
+		summary.Verdict = VerdictMetadata
+		summary.Details = strings.Join(metaDiffs, ", ")
+		fmt.Fprintf(&sb, "  %s\n", diffFn(fmt.Sprintf("✗ Files have the same content but DIFFERENT metadata (%s)", summary.Details)))
+	} else if sameContent {
+		summary.Verdict = VerdictIdentical
+		fmt.Fprintf(&sb, "  %s\n", matchFn("✓ Files are IDENTICAL (same content)"))
+	} else if archiveSame && DiffOpts.IgnoreVolatile {
+		summary.Verdict = VerdictEquivalent
+		summary.Details = "same archive entries"
+		fmt.Fprintf(&sb, "  %s\n", matchFn("✓ Files are EQUIVALENT (same archive entries, different encoding)"))
+	} else if equivalent {
+		summary.Verdict = VerdictEquivalent
+		summary.Details = "differ only in volatile regions"
+		fmt.Fprintf(&sb, "  %s\n", matchFn("✓ Files are EQUIVALENT (differ only in volatile regions)"))
+	} else {
+		summary.Verdict = VerdictDifferent
+		summary.Details = details
+		if info1.Size() == info2.Size() && !ignoresField(ignore, "size") {
+			summary.Details = "same size, different content, " + details
+		}
+		fmt.Fprintf(&sb, "  %s\n", diffFn(fmt.Sprintf("✗ Files are DIFFERENT (%s)", summary.Details)))
+	}
+
 	sb.WriteString("\n")
-	return sb.String(), nil
+	return sb.String(), summary, nil
+}
+
+// bothText reports whether both files are detected as text
+func bothText(path1, path2 string) bool {
+	type1, err := DetectFileType(path1)
+	if err != nil || !type1.IsText {
+		return false
+	}
+	type2, err := DetectFileType(path2)
+	return err == nil && type2.IsText
 }
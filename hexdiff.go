@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	hexRowWidth      = 8  // bytes per hexdump row on each side
+	hexContextRows   = 1  // unchanged rows shown around each region
+	maxListedRanges  = 20 // differing ranges listed individually
+	maxDumpedRegions = 8  // regions shown as hexdumps
+	maxDumpedRows    = 16 // rows shown per hexdump region
+	maxStoredRanges  = 10000
+	compareBlockSize = 64 << 10 // bytes compared at once before any per-byte work
+)
+
+// ByteRange is a half-open range of file offsets [Start, End)
+type ByteRange struct {
+	Start, End int64
+	Label      string // for volatile regions, what they are
+}
+
+// ByteDiff describes where two binary files differ
+type ByteDiff struct {
+	Size1, Size2  int64
+	FirstDiff     int64 // -1 when no bytes differ
+	Ranges        []ByteRange
+	RangeCount    int   // total ranges, including any not stored
+	DiffBytes     int64 // differing bytes, including a size difference
+	IgnoredBytes  int64 // differing bytes inside ignored volatile regions
+	Volatile      []ByteRange
+	ChangePercent float64
+}
+
+// DiffBinaryFiles compares two files byte by byte. With ignoreVolatile, ELF
+// build-id notes and Mach-O UUIDs in either file are skipped.
+func DiffBinaryFiles(path1, path2 string, ignoreVolatile bool) (*ByteDiff, error) {
+	f1, err := os.Open(path1)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f1.Close() }()
+	f2, err := os.Open(path2)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f2.Close() }()
+
+	st1, err := f1.Stat()
+	if err != nil {
+		return nil, err
+	}
+	st2, err := f2.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	bd := &ByteDiff{Size1: st1.Size(), Size2: st2.Size(), FirstDiff: -1}
+	if ignoreVolatile {
+		bd.Volatile = volatileRegions(path1)
+		for _, r := range volatileRegions(path2) {
+			if !containsRange(bd.Volatile, r) {
+				bd.Volatile = append(bd.Volatile, r)
+			}
+		}
+	}
+
+	r1 := &contextReader{ctx: HashContext, r: f1}
+	r2 := &contextReader{ctx: HashContext, r: f2}
+	buf1 := make([]byte, compareBlockSize)
+	buf2 := make([]byte, compareBlockSize)
+	common := min(bd.Size1, bd.Size2)
+	inRange := false
+
+	for base := int64(0); base < common; base += compareBlockSize {
+		n := int(min(compareBlockSize, common-base))
+		if _, err := io.ReadFull(r1, buf1[:n]); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r2, buf2[:n]); err != nil {
+			return nil, err
+		}
+		// Only blocks that differ are walked byte by byte
+		if bytes.Equal(buf1[:n], buf2[:n]) {
+			inRange = false
+			continue
+		}
+
+		for i := 0; i < n; i++ {
+			off := base + int64(i)
+			differs := buf1[i] != buf2[i]
+			if differs && bd.isVolatile(off) {
+				bd.IgnoredBytes++
+				differs = false
+			}
+			if !differs {
+				inRange = false
+				continue
+			}
+
+			bd.DiffBytes++
+			if bd.FirstDiff < 0 {
+				bd.FirstDiff = off
+			}
+			if inRange && len(bd.Ranges) > 0 && bd.Ranges[len(bd.Ranges)-1].End == off {
+				bd.Ranges[len(bd.Ranges)-1].End = off + 1
+			} else {
+				bd.addRange(ByteRange{Start: off, End: off + 1})
+			}
+			inRange = true
+		}
+	}
+
+	// Trailing bytes of the longer file count as changed
+	if bd.Size1 != bd.Size2 {
+		longest := max(bd.Size1, bd.Size2)
+		bd.DiffBytes += longest - common
+		if bd.FirstDiff < 0 {
+			bd.FirstDiff = common
+		}
+		if inRange && len(bd.Ranges) > 0 && bd.Ranges[len(bd.Ranges)-1].End == common {
+			bd.Ranges[len(bd.Ranges)-1].End = longest
+		} else {
+			bd.addRange(ByteRange{Start: common, End: longest})
+		}
+	}
+
+	if longest := max(bd.Size1, bd.Size2); longest > 0 {
+		bd.ChangePercent = float64(bd.DiffBytes) / float64(longest) * 100
+	}
+	return bd, nil
+}
+
+// addRange records a new differing range, keeping a bounded number
+func (bd *ByteDiff) addRange(r ByteRange) {
+	bd.RangeCount++
+	if len(bd.Ranges) < maxStoredRanges {
+		bd.Ranges = append(bd.Ranges, r)
+	}
+}
+
+// isVolatile reports whether off lies in an ignored region
+func (bd *ByteDiff) isVolatile(off int64) bool {
+	for _, r := range bd.Volatile {
+		if off >= r.Start && off < r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// containsRange reports whether ranges already has r
+func containsRange(ranges []ByteRange, r ByteRange) bool {
+	for _, existing := range ranges {
+		if existing.Start == r.Start && existing.End == r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// volatileRegions finds byte ranges that change on every build even when the code doesn't
+func volatileRegions(path string) []ByteRange {
+	var regions []ByteRange
+
+	if f, err := elf.Open(path); err == nil {
+		defer func() { _ = f.Close() }()
+		if s := f.Section(".note.gnu.build-id"); s != nil && s.Type != elf.SHT_NOBITS {
+			regions = append(regions, ByteRange{Start: int64(s.Offset), End: int64(s.Offset + s.Size), Label: "ELF build-id"})
+		}
+		return regions
+	}
+
+	if f, err := macho.Open(path); err == nil {
+		defer func() { _ = f.Close() }()
+		// Load commands follow the Mach-O header
+		offset := int64(28)
+		if f.Magic == macho.Magic64 {
+			offset = 32
+		}
+		for _, load := range f.Loads {
+			raw := load.Raw()
+			if len(raw) >= 24 && f.ByteOrder.Uint32(raw) == uint32(lcUUID) {
+				regions = append(regions, ByteRange{Start: offset + 8, End: offset + 24, Label: "Mach-O UUID"})
+			}
+			offset += int64(len(raw))
+		}
+	}
+	return regions
+}
+
+// lcUUID is the Mach-O LC_UUID load command
+const lcUUID = 0x1b
+
+// FormatByteDiff renders differing ranges and side-by-side hexdumps with colors
+func FormatByteDiff(bd *ByteDiff, path1, path2 string, labelFn, treeFn, matchFn, diffFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+
+	if bd.DiffBytes == 0 {
+		fmt.Fprintf(&sb, "  %s %s\n", matchFn("✓"), valueFn("No differing bytes"))
+	} else {
+		fmt.Fprintf(&sb, "  %s First difference at %s\n", diffFn("✗"), valueFn(fmt.Sprintf("0x%08x (%d)", bd.FirstDiff, bd.FirstDiff)))
+		fmt.Fprintf(&sb, "  %s %s differ (%s), in %s\n", diffFn("✗"),
+			valueFn(fmt.Sprintf("%d bytes", bd.DiffBytes)),
+			valueFn(fmt.Sprintf("%.2f%%", bd.ChangePercent)),
+			valueFn(fmt.Sprintf("%d range(s)", bd.RangeCount)))
+	}
+	if bd.IgnoredBytes > 0 || len(bd.Volatile) > 0 {
+		var labels []string
+		for _, r := range bd.Volatile {
+			labels = append(labels, fmt.Sprintf("%s 0x%x-0x%x", r.Label, r.Start, r.End))
+		}
+		fmt.Fprintf(&sb, "  %s Ignored %s in volatile regions (%s)\n", treeFn("•"),
+			valueFn(fmt.Sprintf("%d bytes", bd.IgnoredBytes)), valueFn(strings.Join(labels, ", ")))
+	}
+
+	if len(bd.Ranges) > 0 {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn("Changed ranges:"))
+		limit := min(len(bd.Ranges), maxListedRanges)
+		for i := 0; i < limit; i++ {
+			r := bd.Ranges[i]
+			branch := "├──"
+			if i == limit-1 && bd.RangeCount <= limit {
+				branch = "╰──"
+			}
+			fmt.Fprintf(&sb, "  %s %s\n", treeFn(branch),
+				valueFn(fmt.Sprintf("0x%08x-0x%08x (%d bytes)", r.Start, r.End, r.End-r.Start)))
+		}
+		if bd.RangeCount > limit {
+			fmt.Fprintf(&sb, "  %s %s\n", treeFn("╰──"), valueFn(fmt.Sprintf("... and %d more", bd.RangeCount-limit)))
+		}
+
+		sb.WriteString(formatHexRegions(bd, path1, path2, labelFn, diffFn, valueFn))
+	}
+
+	return sb.String()
+}
+
+// formatHexRegions prints a side-by-side hexdump of the first changed regions
+func formatHexRegions(bd *ByteDiff, path1, path2 string, labelFn, diffFn, valueFn func(a ...interface{}) string) string {
+	f1, err := os.Open(path1)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f1.Close() }()
+	f2, err := os.Open(path2)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f2.Close() }()
+
+	// Merge ranges whose hexdump windows would overlap
+	var windows []ByteRange
+	limited := false
+	for _, r := range bd.Ranges {
+		start := (r.Start/hexRowWidth - hexContextRows) * hexRowWidth
+		end := (((r.End - 1) / hexRowWidth) + 1 + hexContextRows) * hexRowWidth
+		start = max(start, 0)
+		if n := len(windows); n > 0 && start <= windows[n-1].End {
+			windows[n-1].End = max(windows[n-1].End, end)
+			continue
+		}
+		if len(windows) == maxDumpedRegions {
+			limited = true
+			break
+		}
+		windows = append(windows, ByteRange{Start: start, End: end})
+	}
+
+	var sb strings.Builder
+	for _, w := range windows {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn(fmt.Sprintf("@@ 0x%08x-0x%08x @@", w.Start, w.End)))
+		row1 := make([]byte, hexRowWidth)
+		row2 := make([]byte, hexRowWidth)
+		for off := w.Start; off < w.End; off += hexRowWidth {
+			if off >= w.Start+maxDumpedRows*hexRowWidth {
+				fmt.Fprintf(&sb, "%s\n", valueFn(fmt.Sprintf("... %d more bytes", w.End-off)))
+				break
+			}
+			n1, _ := f1.ReadAt(row1, off)
+			n2, _ := f2.ReadAt(row2, off)
+			if n1 == 0 && n2 == 0 {
+				break
+			}
+			left, right := hexRow(row1[:n1], row2[:n2], off, bd, diffFn, valueFn)
+			fmt.Fprintf(&sb, "%s  %s  %s\n", valueFn(fmt.Sprintf("%08x", off)), left, right)
+		}
+	}
+	if limited {
+		fmt.Fprintf(&sb, "%s\n", valueFn(fmt.Sprintf("(hexdump limited to the first %d regions)", maxDumpedRegions)))
+	}
+	return sb.String()
+}
+
+// hexRow renders one row of both files, highlighting bytes that differ
+func hexRow(a, b []byte, off int64, bd *ByteDiff, diffFn, valueFn func(a ...interface{}) string) (string, string) {
+	render := func(self, other []byte) string {
+		var hexPart, asciiPart strings.Builder
+		for i := 0; i < hexRowWidth; i++ {
+			if i >= len(self) {
+				hexPart.WriteString("   ")
+				asciiPart.WriteString(" ")
+				continue
+			}
+			c := self[i]
+			ch := "."
+			if c >= 32 && c < 127 {
+				ch = string(c)
+			}
+			colorFn := valueFn
+			if (i >= len(other) || other[i] != c) && !bd.isVolatile(off+int64(i)) {
+				colorFn = diffFn
+			}
+			hexPart.WriteString(colorFn(fmt.Sprintf("%02x", c)) + " ")
+			asciiPart.WriteString(colorFn(ch))
+		}
+		return hexPart.String() + "|" + asciiPart.String() + "|"
+	}
+	return render(a, b), render(b, a)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Dynamic loader configuration, as read by ldconfig(8) and ld.so(8)
+const (
+	ldSoConf  = "/etc/ld.so.conf"
+	ldSoCache = "/etc/ld.so.cache"
+
+	ldCacheMagicOld = "ld.so-1.7.0"
+	ldCacheMagicNew = "glibc-ld.so.cache1.1"
+)
+
+// LdCacheEntry is one library in /etc/ld.so.cache
+type LdCacheEntry struct {
+	Soname string
+	Path   string
+	Arch   string // from the entry flags; empty for the 32-bit default
+}
+
+// ldCacheArch maps the required-hardware bits of a cache entry to an architecture
+var ldCacheArch = map[int32]string{
+	0x0100: "sparc64",
+	0x0200: "ia64",
+	0x0300: "x86-64",
+	0x0400: "s390x",
+	0x0500: "ppc64",
+	0x0600: "mips64",
+	0x0700: "mips64",
+	0x0800: "x32",
+	0x0900: "arm",
+	0x0a00: "aarch64",
+	0x0b00: "arm",
+	0x0f00: "riscv64",
+	0x1000: "riscv64",
+}
+
+// multiarchTriplets maps GOARCH to the Debian multiarch directory name
+var multiarchTriplets = map[string]string{
+	"amd64":   "x86_64-linux-gnu",
+	"386":     "i386-linux-gnu",
+	"arm64":   "aarch64-linux-gnu",
+	"arm":     "arm-linux-gnueabihf",
+	"riscv64": "riscv64-linux-gnu",
+	"ppc64le": "powerpc64le-linux-gnu",
+	"s390x":   "s390x-linux-gnu",
+}
+
+// ReadLdSoConf returns the directories listed in an ld.so.conf file,
+// following include lines (globs, relative to the including file)
+func ReadLdSoConf(path string) []string {
+	var dirs []string
+	readLdSoConf(path, make(map[string]bool), &dirs)
+	return dirs
+}
+
+func readLdSoConf(path string, visited map[string]bool, dirs *[]string) {
+	if visited[path] {
+		return
+	}
+	visited[path] = true
+
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "include":
+			for _, pattern := range fields[1:] {
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(filepath.Dir(path), pattern)
+				} else {
+					pattern = rootRealPath(pattern)
+				}
+				matches, _ := filepath.Glob(pattern)
+				for _, m := range matches {
+					readLdSoConf(m, visited, dirs)
+				}
+			}
+		case "hwcap":
+			// Obsolete hardware capability lines name no directory
+		default:
+			for _, dir := range fields {
+				// An optional "=TYPE" suffix forces the library type
+				dir, _, _ = strings.Cut(dir, "=")
+				*dirs = append(*dirs, rootRealPath(strings.TrimRight(dir, "/")))
+			}
+		}
+	}
+}
+
+// ReadLdSoCache parses the binary cache written by ldconfig. Both the current
+// format and the old one that prefixes it on older systems are accepted.
+func ReadLdSoCache(path string) ([]LdCacheEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The new-format cache follows the old-format entries, if there are any;
+	// its string offsets are relative to its own header
+	base := bytes.Index(data, []byte(ldCacheMagicNew))
+	if base < 0 {
+		if bytes.HasPrefix(data, []byte(ldCacheMagicOld)) {
+			return nil, fmt.Errorf("%s: only the old libc5 cache format is present", path)
+		}
+		return nil, fmt.Errorf("%s: not an ld.so cache", path)
+	}
+	cache := data[base:]
+	if len(cache) < 48 {
+		return nil, fmt.Errorf("%s: truncated header", path)
+	}
+
+	var order binary.ByteOrder = binary.NativeEndian
+	switch cache[28] {
+	case 2:
+		order = binary.LittleEndian
+	case 3:
+		order = binary.BigEndian
+	}
+
+	nlibs := int(order.Uint32(cache[20:24]))
+	const entrySize = 24
+	if nlibs < 0 || 48+nlibs*entrySize > len(cache) {
+		return nil, fmt.Errorf("%s: truncated entries", path)
+	}
+
+	cstring := func(off uint32) string {
+		if int(off) >= len(cache) {
+			return ""
+		}
+		s := cache[off:]
+		if end := bytes.IndexByte(s, 0); end >= 0 {
+			s = s[:end]
+		}
+		return string(s)
+	}
+
+	entries := make([]LdCacheEntry, 0, nlibs)
+	for i := 0; i < nlibs; i++ {
+		e := cache[48+i*entrySize:]
+		flags := int32(order.Uint32(e[0:4]))
+		entries = append(entries, LdCacheEntry{
+			Soname: cstring(order.Uint32(e[4:8])),
+			Path:   cstring(order.Uint32(e[8:12])),
+			Arch:   ldCacheArch[flags&0xff00],
+		})
+	}
+	return entries, nil
+}
+
+// loaderCache reads the dynamic loader's cache, with paths mapped into the root
+func loaderCache() []LdCacheEntry {
+	cache, _ := ReadLdSoCache(rootRealPath(ldSoCache))
+	for i := range cache {
+		cache[i].Path = rootPath(cache[i].Path)
+	}
+	return cache
+}
+
+// multiarchDirs lists the existing multiarch library directories, the host's first
+func multiarchDirs() []string {
+	var dirs []string
+	for _, parent := range []string{rootRealPath("/lib"), rootRealPath("/usr/lib"), rootRealPath("/usr/local/lib")} {
+		if triplet, ok := multiarchTriplets[runtime.GOARCH]; ok {
+			dirs = append(dirs, filepath.Join(parent, triplet))
+		}
+		matches, _ := filepath.Glob(filepath.Join(parent, "*-linux-*"))
+		dirs = append(dirs, matches...)
+	}
+	var existing []string
+	for _, dir := range dirs {
+		if info, err := rootStat(dir); err == nil && info.IsDir() && !containsString(existing, dir) {
+			existing = append(existing, dir)
+		}
+	}
+	return existing
+}
+
+// loaderDefaultDirs are searched by ld.so after the cache
+func loaderDefaultDirs() []string {
+	var dirs []string
+	if triplet, ok := multiarchTriplets[runtime.GOARCH]; ok {
+		dirs = append(dirs, "/lib/"+triplet, "/usr/lib/"+triplet)
+	}
+	dirs = append(dirs, "/lib64", "/usr/lib64", "/lib", "/usr/lib")
+	for i, dir := range dirs {
+		dirs[i] = rootRealPath(dir)
+	}
+	return dirs
+}
+
+// elfArch names the architecture of an ELF file the way ld.so.cache does
+func elfArch(f *elf.File) string {
+	switch f.Machine {
+	case elf.EM_X86_64:
+		if f.Class == elf.ELFCLASS32 {
+			return "x32"
+		}
+		return "x86-64"
+	case elf.EM_386:
+		return "i386"
+	case elf.EM_AARCH64:
+		return "aarch64"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_PPC64:
+		return "ppc64"
+	case elf.EM_S390:
+		if f.Class == elf.ELFCLASS64 {
+			return "s390x"
+		}
+		return "s390"
+	case elf.EM_RISCV:
+		if f.Class == elf.ELFCLASS64 {
+			return "riscv64"
+		}
+		return "riscv32"
+	}
+	return strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_"))
+}
+
+// loaderPick returns the file ld.so would load for soname: LD_LIBRARY_PATH
+// first, then the first compatible cache entry, then the default directories
+func loaderPick(soname, arch string, is64 bool, ldLibraryPath []string, cache []LdCacheEntry) string {
+	for _, dir := range ldLibraryPath {
+		if path := filepath.Join(dir, soname); fileExists(path) {
+			return path
+		}
+	}
+	for _, e := range cache {
+		if e.Soname == soname && (e.Arch == arch || (e.Arch == "" && !is64)) {
+			return e.Path
+		}
+	}
+	for _, dir := range loaderDefaultDirs() {
+		if path := filepath.Join(dir, soname); fileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// fileExists reports whether path names an existing file, following symlinks
+func fileExists(path string) bool {
+	info, err := rootStat(path)
+	return err == nil && !info.IsDir()
+}
+
+// sameFile reports whether two paths name the same file once symlinked
+// directories are resolved
+func sameFile(a, b string) bool {
+	if a == b {
+		return true
+	}
+	da, errA := evalSymlinks(filepath.Dir(a))
+	db, errB := evalSymlinks(filepath.Dir(b))
+	return errA == nil && errB == nil && da == db && filepath.Base(a) == filepath.Base(b)
+}
+
+// cachedFiles indexes the cache entries by path with symlinked directories
+// resolved, so membership can be checked the way sameFile compares paths.
+// Each directory is resolved once.
+func cachedFiles(cache []LdCacheEntry) map[string]bool {
+	dirs := make(map[string]string)
+	files := make(map[string]bool, len(cache))
+	for _, e := range cache {
+		dir := filepath.Dir(e.Path)
+		resolved, ok := dirs[dir]
+		if !ok {
+			if r, err := evalSymlinks(dir); err == nil {
+				resolved = r
+			}
+			dirs[dir] = resolved
+		}
+		if resolved != "" {
+			files[filepath.Join(resolved, filepath.Base(e.Path))] = true
+		}
+	}
+	return files
+}
+
+// inCachedFiles reports whether path is one of the files indexed by cachedFiles
+func inCachedFiles(files map[string]bool, path string) bool {
+	dir, err := evalSymlinks(filepath.Dir(path))
+	return err == nil && files[filepath.Join(dir, filepath.Base(path))]
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeLdCache builds a glibc-ld.so.cache1.1 file, optionally behind the old
+// libc5 format, and returns its path
+func writeLdCache(t *testing.T, order binary.ByteOrder, oldPrefix bool, entries []LdCacheEntry, flags []int32) string {
+	t.Helper()
+	const headerSize, entrySize = 48, 24
+
+	// String offsets are relative to the new-format header
+	strtab := headerSize + len(entries)*entrySize
+	var strs bytes.Buffer
+	offset := func(s string) uint32 {
+		off := uint32(strtab + strs.Len())
+		strs.WriteString(s)
+		strs.WriteByte(0)
+		return off
+	}
+
+	var body bytes.Buffer
+	body.WriteString(ldCacheMagicNew)
+	_ = binary.Write(&body, order, uint32(len(entries)))
+	_ = binary.Write(&body, order, uint32(0)) // string table length, filled in below
+	endian := byte(2)
+	if order == binary.BigEndian {
+		endian = 3
+	}
+	body.Write([]byte{endian, 0, 0, 0})
+	body.Write(make([]byte, headerSize-body.Len()))
+	for i, e := range entries {
+		_ = binary.Write(&body, order, flags[i])
+		_ = binary.Write(&body, order, offset(e.Soname))
+		_ = binary.Write(&body, order, offset(e.Path))
+		_ = binary.Write(&body, order, uint32(0)) // osversion
+		_ = binary.Write(&body, order, uint64(0)) // hwcap
+	}
+	data := append(body.Bytes(), strs.Bytes()...)
+	order.PutUint32(data[24:28], uint32(strs.Len()))
+
+	if oldPrefix {
+		// An old-format header with no entries of its own
+		old := append([]byte(ldCacheMagicOld), 0, 0, 0, 0, 0)
+		data = append(old, data...)
+	}
+
+	path := filepath.Join(t.TempDir(), "ld.so.cache")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadLdSoCache(t *testing.T) {
+	entries := []LdCacheEntry{
+		{Soname: "libssl.so.3", Path: "/usr/lib/x86_64-linux-gnu/libssl.so.3", Arch: "x86-64"},
+		{Soname: "libc.so.6", Path: "/usr/lib/i386-linux-gnu/libc.so.6"},
+		{Soname: "libz.so.1", Path: "/usr/lib/aarch64-linux-gnu/libz.so.1", Arch: "aarch64"},
+	}
+	// ELF libc6 type (0x03) combined with the required-hardware bits
+	flags := []int32{0x0303, 0x0003, 0x0a03}
+
+	for _, tt := range []struct {
+		name      string
+		order     binary.ByteOrder
+		oldPrefix bool
+	}{
+		{"little endian", binary.LittleEndian, false},
+		{"big endian", binary.BigEndian, false},
+		{"after the old format", binary.LittleEndian, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadLdSoCache(writeLdCache(t, tt.order, tt.oldPrefix, entries, flags))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, entries) {
+				t.Errorf("got %+v, want %+v", got, entries)
+			}
+		})
+	}
+}
+
+func TestReadLdSoCacheErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	truncated := writeLdCache(t, binary.LittleEndian, false, []LdCacheEntry{{Soname: "a", Path: "/a"}}, []int32{3})
+	data, err := os.ReadFile(truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		path string
+		want string
+	}{
+		"missing":      {filepath.Join(dir, "missing"), "no such file"},
+		"not a cache":  {write("text", []byte("hello")), "not an ld.so cache"},
+		"libc5 only":   {write("old", []byte(ldCacheMagicOld+"\x00\x00\x00\x00")), "old libc5"},
+		"short header": {write("short", []byte(ldCacheMagicNew+"\x01")), "truncated header"},
+		"short table":  {write("entries", data[:48+10]), "truncated entries"},
+	}
+	for name, tt := range tests {
+		if _, err := ReadLdSoCache(tt.path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", name, err, tt.want)
+		}
+	}
+}
+
+func TestReadLdSoConf(t *testing.T) {
+	dir := t.TempDir()
+	confD := filepath.Join(dir, "ld.so.conf.d")
+	if err := os.Mkdir(confD, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"ld.so.conf":               "# comment\ninclude ld.so.conf.d/*.conf\n/usr/local/lib\n",
+		"ld.so.conf.d/a.conf":      "/opt/a/lib/ /opt/b/lib=libc6\nhwcap 0 nosegneg\n",
+		"ld.so.conf.d/b.conf":      "include ../ld.so.conf\n/opt/c/lib # trailing comment\n",
+		"ld.so.conf.d/ignored.txt": "/never\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Includes are read where they appear, and a file including itself is read once
+	want := []string{"/opt/a/lib", "/opt/b/lib", "/opt/c/lib", "/usr/local/lib"}
+	if got := ReadLdSoConf(filepath.Join(dir, "ld.so.conf")); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Library match modes for --lib
+const (
+	LibMatchName  = "name"  // lib<NAME>.so, .so.*, .a or .dylib
+	LibMatchGlob  = "glob"  // shell pattern against the file name
+	LibMatchRegex = "regex" // regular expression against the file name
+	LibMatchFuzzy = "fuzzy" // substring, subsequence or small typo in the library name
+)
+
+// dylibVersion matches the version in libfoo.1.2.3.dylib
+var dylibVersion = regexp.MustCompile(`\.(\d+(?:\.\d+)*)$`)
+
+// LibraryFamily is a library file and the symlinks in the results that lead to it
+type LibraryFamily struct {
+	Primary *LibraryMatch
+	Links   []string // symlinks such as libfoo.so and libfoo.so.1
+	Real    string   // the file the symlinks resolve to
+	Version string
+}
+
+// loaderStatus is "picked" when the dynamic loader would load this family for
+// its SONAME, "shadowed" when it would load another copy, and "" otherwise
+func (fam *LibraryFamily) loaderStatus() string {
+	m := fam.Primary
+	if m.Soname == "" || m.Picked == "" {
+		return ""
+	}
+	// The loader opens the SONAME link, which need not be the family's lead file
+	for _, p := range append([]string{m.Path, fam.Real}, fam.Links...) {
+		if filepath.Base(p) == m.Soname && sameFile(p, m.Picked) {
+			return "picked"
+		}
+	}
+	if real, err := evalSymlinks(m.Picked); err == nil && real == fam.Real {
+		return "picked"
+	}
+	return "shadowed"
+}
+
+// LibraryGroup collects the families of one library, newest first
+type LibraryGroup struct {
+	Name     string
+	Families []*LibraryFamily
+}
+
+// libraryMatcher returns a function scoring a file name against query, or -1
+// when it does not match. An empty mode means glob when query has wildcards
+// and name otherwise.
+func libraryMatcher(query, mode string) (func(base string) int, error) {
+	if mode == "" {
+		mode = LibMatchName
+		if strings.ContainsAny(query, "*?[") {
+			mode = LibMatchGlob
+		}
+	}
+	libName := normalizeLibName(query)
+
+	switch mode {
+	case LibMatchName:
+		patterns := []string{
+			fmt.Sprintf("lib%s.dylib", libName),
+			fmt.Sprintf("lib%s.*.dylib", libName),
+			fmt.Sprintf("lib%s.so", libName),
+			fmt.Sprintf("lib%s.so.*", libName),
+			fmt.Sprintf("lib%s.a", libName),
+			fmt.Sprintf("%s.dylib", libName),
+			fmt.Sprintf("%s.so", libName),
+			fmt.Sprintf("%s.a", libName),
+		}
+		return func(base string) int {
+			for _, pattern := range patterns {
+				if ok, _ := filepath.Match(pattern, base); ok {
+					return 0
+				}
+			}
+			return -1
+		}, nil
+
+	case LibMatchGlob:
+		if _, err := filepath.Match(query, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob '%s': %w", query, err)
+		}
+		return func(base string) int {
+			if ok, _ := filepath.Match(query, base); ok {
+				return 0
+			}
+			// "ssl*" should find libssl.so.3 without spelling out the prefix
+			if ok, _ := filepath.Match("lib"+query, base); ok && !strings.HasPrefix(query, "lib") {
+				return 0
+			}
+			return -1
+		}, nil
+
+	case LibMatchRegex:
+		re, err := regexp.Compile(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex '%s': %w", query, err)
+		}
+		return func(base string) int {
+			if re.MatchString(base) {
+				return 0
+			}
+			return -1
+		}, nil
+
+	case LibMatchFuzzy:
+		q := strings.ToLower(libName)
+		return func(base string) int {
+			stem, _ := libraryStem(base)
+			return fuzzyLibraryScore(q, strings.ToLower(strings.TrimPrefix(stem, "lib")))
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown match mode '%s' (expected %s, %s, %s or %s)", mode, LibMatchName, LibMatchGlob, LibMatchRegex, LibMatchFuzzy)
+}
+
+// fuzzyLibraryScore ranks a library name against a query: 0 for the same
+// name, 1 when it contains the query, 2 when the query's letters appear in
+// order, and 3 plus the edit distance for near misses; -1 otherwise
+func fuzzyLibraryScore(query, name string) int {
+	switch {
+	case name == query:
+		return 0
+	case strings.Contains(name, query):
+		return 1
+	case isSubsequence(query, name):
+		return 2
+	}
+	// A replaced character costs 2, so this allows about one typo per four characters
+	if d := editDistance(query, name); d <= max(2, len(query)/2) {
+		return 3 + d
+	}
+	return -1
+}
+
+// isSubsequence reports whether the characters of a appear in b in order
+func isSubsequence(a, b string) bool {
+	i := 0
+	for j := 0; i < len(a) && j < len(b); j++ {
+		if a[i] == b[j] {
+			i++
+		}
+	}
+	return i == len(a)
+}
+
+// isLibraryFile reports whether a file name looks like a shared or static library
+func isLibraryFile(base string) bool {
+	return strings.HasSuffix(base, ".so") || strings.Contains(base, ".so.") ||
+		strings.HasSuffix(base, ".a") || strings.HasSuffix(base, ".dylib")
+}
+
+// libraryStem splits a library file name into its name and version:
+// libfoo.so.1.2 and libfoo.1.2.dylib are both ("libfoo", "1.2")
+func libraryStem(base string) (string, string) {
+	switch {
+	case strings.Contains(base, ".so."):
+		i := strings.Index(base, ".so.")
+		return base[:i], base[i+len(".so."):]
+	case strings.HasSuffix(base, ".so"):
+		return strings.TrimSuffix(base, ".so"), ""
+	case strings.HasSuffix(base, ".a"):
+		return strings.TrimSuffix(base, ".a"), ""
+	case strings.HasSuffix(base, ".dylib"):
+		name := strings.TrimSuffix(base, ".dylib")
+		if m := dylibVersion.FindStringSubmatchIndex(name); m != nil {
+			return name[:m[0]], name[m[2]:m[3]]
+		}
+		return name, ""
+	}
+	return base, ""
+}
+
+// isStaticLibrary reports whether path is a static archive
+func isStaticLibrary(path string) bool {
+	return strings.HasSuffix(path, ".a")
+}
+
+// sortLibraries orders matches by score, then library name, shared before
+// static, newest version first, and finally search order
+func sortLibraries(found []*LibraryMatch) {
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.score != b.score {
+			return a.score < b.score
+		}
+		stemA, versionA := libraryStem(filepath.Base(a.Path))
+		stemB, versionB := libraryStem(filepath.Base(b.Path))
+		if stemA != stemB {
+			return stemA < stemB
+		}
+		if isStaticLibrary(a.Path) != isStaticLibrary(b.Path) {
+			return !isStaticLibrary(a.Path)
+		}
+		if c := compareVersions(versionA, versionB); c != 0 {
+			return c > 0
+		}
+		return a.order < b.order
+	})
+}
+
+// GroupLibraries collapses symlink families (libfoo.so → libfoo.so.1 →
+// libfoo.so.1.2.3) into one entry and groups them by library name, keeping
+// the order of paths for the groups
+func GroupLibraries(paths []string) []*LibraryGroup {
+	var groups []*LibraryGroup
+	groupByName := make(map[string]*LibraryGroup)
+	familyByReal := make(map[string]*LibraryFamily)
+
+	for _, path := range paths {
+		abs, _ := filepath.Abs(path)
+		m := libraryMatches[abs]
+		if m == nil {
+			m = &LibraryMatch{Path: path}
+		}
+		real, err := evalSymlinks(path)
+		if err != nil {
+			real = path
+		}
+		info, err := os.Lstat(path)
+		isLink := err == nil && info.Mode()&os.ModeSymlink != 0
+
+		if fam, ok := familyByReal[real]; ok {
+			// The file itself leads the family; symlinks are listed under it
+			if !isLink {
+				fam.Links = append(fam.Links, fam.Primary.Path)
+				fam.Primary = m
+			} else {
+				fam.Links = append(fam.Links, path)
+			}
+			continue
+		}
+
+		fam := &LibraryFamily{Primary: m, Real: real}
+		familyByReal[real] = fam
+		name, _ := libraryStem(filepath.Base(real))
+		g, ok := groupByName[name]
+		if !ok {
+			g = &LibraryGroup{Name: name}
+			groupByName[name] = g
+			groups = append(groups, g)
+		}
+		g.Families = append(g.Families, fam)
+	}
+
+	for _, g := range groups {
+		for _, fam := range g.Families {
+			// The longest version among the names is the most precise
+			for _, p := range append([]string{fam.Real, fam.Primary.Path}, fam.Links...) {
+				if _, v := libraryStem(filepath.Base(p)); len(v) > len(fam.Version) {
+					fam.Version = v
+				}
+			}
+			sort.Slice(fam.Links, func(i, j int) bool { return len(fam.Links[i]) < len(fam.Links[j]) })
+		}
+		sort.SliceStable(g.Families, func(i, j int) bool {
+			a, b := g.Families[i], g.Families[j]
+			if isStaticLibrary(a.Primary.Path) != isStaticLibrary(b.Primary.Path) {
+				return !isStaticLibrary(a.Primary.Path)
+			}
+			return compareVersions(a.Version, b.Version) > 0
+		})
+	}
+	return groups
+}
+
+// FormatLibrarySummary renders one line per library family, grouped by library
+func FormatLibrarySummary(query string, paths []string, labelFn, treeFn, pathFn, matchFn, warnFn, valueFn func(a ...interface{}) string) string {
+	groups := GroupLibraries(paths)
+	var sb strings.Builder
+	noun := "libraries"
+	if len(groups) == 1 {
+		noun = "library"
+	}
+	fmt.Fprintf(&sb, "Found %d library file(s) for '%s' in %d %s:\n", len(paths), query, len(groups), noun)
+
+	for _, g := range groups {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn(g.Name))
+		for i, fam := range g.Families {
+			branch := "├─"
+			if i == len(g.Families)-1 {
+				branch = "╰─"
+			}
+			m := fam.Primary
+			line := fmt.Sprintf("  %s %s", treeFn(branch), pathFn(m.Path))
+			// The version is only worth repeating when the file name doesn't carry
+			// all of it, e.g. the 1.2.3 behind a .so.1 link
+			if _, v := libraryStem(filepath.Base(m.Path)); fam.Version != "" && fam.Version != v {
+				line += " " + valueFn(fam.Version)
+			}
+
+			var notes []string
+			switch {
+			case isStaticLibrary(m.Path):
+				notes = append(notes, "static")
+			case m.Arch != "":
+				notes = append(notes, m.Arch)
+			}
+			if m.Soname != "" && m.Soname != filepath.Base(m.Path) {
+				notes = append(notes, "SONAME "+m.Soname)
+			}
+			if !sameFile(m.Path, fam.Real) {
+				notes = append(notes, "→ "+fam.Real)
+			}
+			if len(fam.Links) > 0 {
+				var links []string
+				for _, l := range fam.Links {
+					links = append(links, filepath.Base(l))
+				}
+				notes = append(notes, "← "+strings.Join(links, ", "))
+			}
+			for _, pc := range m.Packages {
+				if pc.ShadowedBy == "" {
+					notes = append(notes, "pkg-config "+pc.Module)
+				}
+			}
+			if len(notes) > 0 {
+				line += " " + treeFn("("+strings.Join(notes, ", ")+")")
+			}
+
+			switch fam.loaderStatus() {
+			case "picked":
+				line += " " + matchFn("✓ loader pick")
+			case "shadowed":
+				line += " " + warnFn("✗ shadowed")
+			}
+			sb.WriteString(line + "\n")
+		}
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"context"
+	"strings"
+
+	"github.com/fatih/color"
 	"github.com/oh-tarnished/finfo/cmd"
 )
 
@@ -23,16 +27,172 @@ func main() {
 	cmd.SetDisableColorsFunc = func(disable bool) {
 		DisableColors = disable
 	}
+	cmd.SetRootFunc = SetSysroot
+	cmd.RootPathFunc = rootPath
 	cmd.ResolveCommandFunc = func(name string) (string, error) {
 		return ResolveCommand(name)
 	}
-	cmd.FindLibraryFunc = func(name string) ([]string, error) {
-		return FindLibrary(name)
+	cmd.FindLibraryFunc = func(name, mode string) ([]string, error) {
+		return FindLibrary(name, mode)
+	}
+	cmd.FormatLibrarySummaryFunc = func(query string, paths []string) string {
+		if DisableColors {
+			color.NoColor = true
+		}
+		return FormatLibrarySummary(query, paths, labelColor.Sprint, treeColor.Sprint, pathColor.Sprint, execColor.Sprint, warnColor.Sprint, valueColor.Sprint)
+	}
+	cmd.FindSymbolProvidersFunc = func(symbols []string) (string, bool, error) {
+		defs, err := FindSymbolProviders(symbols)
+		if err != nil {
+			return "", false, err
+		}
+		if DisableColors {
+			color.NoColor = true
+		}
+		found := make(map[string]bool)
+		for _, d := range defs {
+			found[d.Query] = true
+		}
+		output := FormatSymbolProviders(defs, symbols, labelColor.Sprint, treeColor.Sprint, pathColor.Sprint, warnColor.Sprint, valueColor.Sprint)
+		return output, len(found) == len(symbols), nil
+	}
+	cmd.FindReverseDepsFunc = func(library string, dirs []string) (string, bool, error) {
+		r, err := FindReverseDeps(library, dirs)
+		if err != nil {
+			return "", false, err
+		}
+		if DisableColors {
+			color.NoColor = true
+		}
+		return FormatReverseDeps(r, labelColor.Sprint, treeColor.Sprint, pathColor.Sprint, warnColor.Sprint, valueColor.Sprint), len(r.Users) > 0, nil
+	}
+	cmd.FindPkgConfigFunc = func(name string) (string, error) {
+		pcs, err := FindPkgConfigPackages(name)
+		if err != nil {
+			return "", err
+		}
+		if DisableColors {
+			color.NoColor = true
+		}
+		return FormatPkgConfigs(pcs, name, labelColor.Sprint, treeColor.Sprint, pathColor.Sprint, warnColor.Sprint, valueColor.Sprint), nil
 	}
 	cmd.SetCalculateHashesFunc = func(enable bool) {
 		CalculateHashesFlag = enable
 	}
-	cmd.CompareFilesFunc = func(path1, path2 string) (string, error) {
+	cmd.SetFuzzyHashFunc = func(enable bool) {
+		FuzzyHashFlag = enable
+	}
+	cmd.SetHashEncodingFunc = func(encoding string) error {
+		return SetHashEncoding(encoding)
+	}
+	cmd.SetSignatureFunc = func(sigPath, keyPath string) {
+		SignatureFile = sigPath
+		SignatureKey = keyPath
+	}
+	cmd.SetProgressFunc = func(ctx context.Context, enable bool, files int) {
+		HashContext = ctx
+		ShowProgressFlag = enable
+		ProgressFileCount = files
+	}
+	cmd.SetDiffOptionsFunc = func(opts cmd.DiffOptions) {
+		DiffOpts = DiffOptions{
+			ContextLines: opts.ContextLines,
+			WordDiff:     opts.WordDiff,
+			MaxTextSize:  opts.MaxTextSize,
+
+			IgnoreVolatile: opts.IgnoreVolatile,
+
+			Excludes:     opts.Excludes,
+			MetadataOnly: opts.MetadataOnly,
+			Detail:       opts.Detail,
+
+			Ignore: opts.Ignore,
+			Strict: opts.Strict,
+		}
+	}
+	cmd.FindDuplicatesFunc = func(paths []string, jsonOut bool, plan string) (string, error) {
+		if plan != "" {
+			if err := ValidatePlanAction(plan); err != nil {
+				return "", err
+			}
+		}
+		report, err := FindDuplicates(paths)
+		if err != nil {
+			return "", err
+		}
+		if jsonOut || plan != "" {
+			return FormatDuplicatesJSON(report, plan)
+		}
+		if DisableColors {
+			color.NoColor = true
+		}
+		return FormatDuplicates(report, labelColor.Sprint, treeColor.Sprint, pathColor.Sprint, sizeColor.Sprint, valueColor.Sprint), nil
+	}
+	cmd.SnapshotFunc = func(paths []string) (string, error) {
+		snap, err := TakeSnapshot(paths)
+		if err != nil {
+			return "", err
+		}
+		return FormatSnapshotJSON(snap)
+	}
+	cmd.DriftFunc = func(baseline string, jsonOut bool, ignore []string) (string, bool, error) {
+		report, err := CheckDrift(baseline, ignore)
+		if err != nil {
+			return "", false, err
+		}
+		if jsonOut {
+			output, err := FormatDriftJSON(report)
+			return output, report.Drifted(), err
+		}
+		if DisableColors {
+			color.NoColor = true
+		}
+		output := FormatDrift(report, labelColor.Sprint, treeColor.Sprint, pathColor.Sprint, pathColor.Sprint, warnColor.Sprint, valueColor.Sprint)
+		return output, report.Drifted(), nil
+	}
+	cmd.VerifyPackagesFunc = func(names []string, all bool) (string, bool, error) {
+		results, err := VerifyPackages(systemRoot(), names)
+		if err != nil {
+			return "", false, err
+		}
+		failed := false
+		for _, v := range results {
+			failed = failed || v.Failed()
+		}
+		if DisableColors {
+			color.NoColor = true
+		}
+		return FormatPackageVerifications(results, all, labelColor.Sprint, treeColor.Sprint, pathColor.Sprint, warnColor.Sprint, valueColor.Sprint), failed, nil
+	}
+	cmd.CheckExpectationsFunc = func(paths []string, exp cmd.Expectations, jsonOut, brief bool) (string, bool, error) {
+		var results []*ExpectResult
+		mismatch := false
+		for _, path := range paths {
+			r, err := CheckExpectations(path, Expectations{Digests: exp.Digests, Size: exp.Size, Mode: exp.Mode})
+			if err != nil {
+				return "", false, err
+			}
+			results = append(results, r)
+			mismatch = mismatch || !r.Match
+		}
+
+		switch {
+		case jsonOut:
+			output, err := FormatExpectJSON(results, brief)
+			return output, mismatch, err
+		case brief:
+			return FormatExpectBrief(results), mismatch, nil
+		}
+		if DisableColors {
+			color.NoColor = true
+		}
+		var sb strings.Builder
+		for _, r := range results {
+			sb.WriteString(FormatExpectResult(r, labelColor.Sprint, treeColor.Sprint, pathColor.Sprint, warnColor.Sprint, valueColor.Sprint))
+		}
+		return sb.String(), mismatch, nil
+	}
+	cmd.CompareFilesFunc = func(paths []string, jsonOut, brief bool) (string, bool, error) {
 		// Import color package functions
 		labelFn := func(a ...interface{}) string { return labelColor.Sprint(a...) }
 		treeFn := func(a ...interface{}) string { return treeColor.Sprint(a...) }
@@ -40,7 +200,38 @@ func main() {
 		diffFn := func(a ...interface{}) string { return warnColor.Sprint(a...) }  // Red for differences
 		valueFn := func(a ...interface{}) string { return valueColor.Sprint(a...) }
 
-		return CompareFiles(path1, path2, labelFn, treeFn, matchFn, diffFn, valueFn)
+		// More than two files are compared as a similarity matrix
+		if len(paths) > 2 {
+			m, err := CompareMany(paths)
+			if err != nil {
+				return "", false, err
+			}
+			var output string
+			switch {
+			case jsonOut:
+				output, err = FormatDiffMatrixJSON(m, brief)
+			case brief:
+				output = FormatDiffMatrixBrief(m)
+			default:
+				output = FormatDiffMatrix(m, labelFn, treeFn, matchFn, diffFn, valueFn)
+			}
+			return output, m.Differs(), err
+		}
+
+		output, summary, err := CompareFiles(paths[0], paths[1], labelFn, treeFn, matchFn, diffFn, valueFn)
+		if err != nil {
+			return "", false, err
+		}
+		switch {
+		case jsonOut:
+			output, err = FormatDiffJSON(summary, brief)
+		case brief:
+			output = FormatDiffBrief(summary)
+		}
+		if err == nil {
+			err = summary.Incomplete()
+		}
+		return output, summary.Differs(DiffOpts.Strict), err
 	}
 
 	cmd.Execute()
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MetadataFields lists the fields --diff compares besides content, in display order
+var MetadataFields = []string{
+	"size", "mode", "mtime", "owner", "group", "type", "mime", "encoding",
+	"interpreter", "symlink", "libs", "stripped", "signature",
+}
+
+// metadataLabels are the display names of each field
+var metadataLabels = map[string]string{
+	"owner":       "Owner",
+	"group":       "Group",
+	"type":        "Type",
+	"mime":        "MIME",
+	"encoding":    "Encoding",
+	"interpreter": "Interpreter",
+	"symlink":     "Symlinks",
+	"libs":        "Libraries",
+	"stripped":    "Stripped",
+	"signature":   "Signature",
+}
+
+// MetadataChange is one compared metadata field
+type MetadataChange struct {
+	Field   string
+	Before  string
+	After   string
+	Added   []string // for list fields, entries only in the second file
+	Removed []string // for list fields, entries only in the first file
+}
+
+// Differs reports whether the field has different values
+func (c MetadataChange) Differs() bool {
+	return c.Before != c.After || len(c.Added) > 0 || len(c.Removed) > 0
+}
+
+// checkIgnoreFields rejects names that are not metadata fields
+func checkIgnoreFields(fields []string) error {
+	for _, f := range fields {
+		if !containsString(MetadataFields, f) {
+			return fmt.Errorf("unknown field '%s' for --ignore (expected one of: %s)", f, strings.Join(MetadataFields, ", "))
+		}
+	}
+	return nil
+}
+
+// ignoresField reports whether field is in the ignore list
+func ignoresField(ignore []string, field string) bool {
+	return containsString(ignore, field)
+}
+
+// containsString reports whether list has s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareMetadata compares the FileInfo fields that are not covered by the
+// size, mode, mtime and content sections. Fields empty on both sides and
+// ignored fields are left out.
+func CompareMetadata(fi1, fi2 *FileInfo, ignore []string) []MetadataChange {
+	var changes []MetadataChange
+	add := func(field, before, after string) {
+		if ignoresField(ignore, field) || (before == "" && after == "") {
+			return
+		}
+		changes = append(changes, MetadataChange{Field: field, Before: before, After: after})
+	}
+
+	add("owner", fi1.Owner, fi2.Owner)
+	add("group", fi1.Group, fi2.Group)
+
+	var type1, type2 FileTypeInfo
+	if fi1.FileType != nil {
+		type1 = *fi1.FileType
+	}
+	if fi2.FileType != nil {
+		type2 = *fi2.FileType
+	}
+	add("type", type1.FileFormat, type2.FileFormat)
+	add("mime", type1.MIMEType, type2.MIMEType)
+	add("encoding", type1.Encoding, type2.Encoding)
+	add("interpreter", type1.Interpreter, type2.Interpreter)
+
+	add("symlink", strings.Join(symlinkTargets(fi1.SymlinkChain), " → "), strings.Join(symlinkTargets(fi2.SymlinkChain), " → "))
+
+	var bin1, bin2 BinaryInfo
+	if fi1.BinaryInfo != nil {
+		bin1 = *fi1.BinaryInfo
+	}
+	if fi2.BinaryInfo != nil {
+		bin2 = *fi2.BinaryInfo
+	}
+	if !ignoresField(ignore, "libs") && (len(bin1.LinkedLibraries) > 0 || len(bin2.LinkedLibraries) > 0) {
+		c := MetadataChange{Field: "libs"}
+		c.Added, c.Removed = setDiff(toSet(bin1.LinkedLibraries), toSet(bin2.LinkedLibraries))
+		c.Before = fmt.Sprintf("%d linked", len(bin1.LinkedLibraries))
+		c.After = fmt.Sprintf("%d linked", len(bin2.LinkedLibraries))
+		changes = append(changes, c)
+	}
+	if fi1.BinaryInfo != nil || fi2.BinaryInfo != nil {
+		add("stripped", yesNo(fi1.BinaryInfo != nil && bin1.IsStripped), yesNo(fi2.BinaryInfo != nil && bin2.IsStripped))
+	}
+
+	add("signature", signatureSummary(fi1.Signature), signatureSummary(fi2.Signature))
+	return changes
+}
+
+// symlinkTargets extracts the link targets from a chain built by resolveSymlinkChain;
+// the link paths themselves always differ between two files and are left out
+func symlinkTargets(chain []string) []string {
+	targets := make([]string, 0, len(chain))
+	for _, hop := range chain {
+		if i := strings.Index(hop, " → "); i >= 0 {
+			hop = hop[i+len(" → "):]
+		}
+		targets = append(targets, hop)
+	}
+	return targets
+}
+
+// yesNo formats a boolean for display
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// signatureSummary describes a detached signature in one line
+func signatureSummary(info *SignatureInfo) string {
+	if info == nil {
+		return ""
+	}
+	status := "unverified"
+	switch {
+	case info.Valid:
+		status = "valid"
+	case info.Checked:
+		status = "INVALID"
+	}
+	if info.KeyID != "" {
+		return fmt.Sprintf("%s, key %s, %s", info.Format, info.KeyID, status)
+	}
+	return fmt.Sprintf("%s, %s", info.Format, status)
+}
+
+// differingFields names the changed fields, for the verdict
+func differingFields(changes []MetadataChange) []string {
+	var fields []string
+	for _, c := range changes {
+		if c.Differs() {
+			fields = append(fields, c.Field)
+		}
+	}
+	return fields
+}
+
+// FormatMetadataDiff renders compared metadata fields with colors
+func FormatMetadataDiff(changes []MetadataChange, treeFn, matchFn, diffFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	width := 0
+	for _, c := range changes {
+		width = max(width, len(metadataLabels[c.Field]))
+	}
+
+	for _, c := range changes {
+		label := fmt.Sprintf("%-*s :", width, metadataLabels[c.Field])
+		if !c.Differs() {
+			fmt.Fprintf(&sb, "  %s %s %s\n", matchFn("✓"), treeFn(label), valueFn(orNone(c.Before)))
+			continue
+		}
+		fmt.Fprintf(&sb, "  %s %s %s → %s\n", diffFn("✗"), treeFn(label), valueFn(orNone(c.Before)), valueFn(orNone(c.After)))
+
+		entries := make([]string, 0, len(c.Removed)+len(c.Added))
+		for _, lib := range c.Removed {
+			entries = append(entries, diffFn("- "+lib))
+		}
+		for _, lib := range c.Added {
+			entries = append(entries, matchFn("+ "+lib))
+		}
+		for i, entry := range entries {
+			branch := "├──"
+			if i == len(entries)-1 {
+				branch = "╰──"
+			}
+			fmt.Fprintf(&sb, "      %s %s\n", treeFn(branch), entry)
+		}
+	}
+	return sb.String()
+}
+
+// orNone shows empty values explicitly
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
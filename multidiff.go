@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MatrixFile is one input of a many-file comparison
+type MatrixFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	SSDeep string `json:"ssdeep"`
+}
+
+// MatrixPair compares two inputs by index
+type MatrixPair struct {
+	A          int     `json:"a"`
+	B          int     `json:"b"`
+	SizeDelta  int64   `json:"size_delta"`         // size of B minus size of A
+	Similarity int     `json:"similarity"`         // fuzzy hash score from 0 to 100
+	ByteMatch  float64 `json:"byte_match_percent"` // bytes equal at the same offset
+	Identical  bool    `json:"identical"`
+}
+
+// DiffMatrix is the result of comparing more than two files
+type DiffMatrix struct {
+	Files       []MatrixFile `json:"files"`
+	Pairs       []MatrixPair `json:"pairs,omitempty"`
+	Groups      [][]int      `json:"identical_groups"`       // indexes of files with the same content
+	Distinct    int          `json:"distinct"`               // number of distinct contents
+	MostSimilar *MatrixPair  `json:"most_similar,omitempty"` // closest pair with different content
+	Outlier     int          `json:"outlier"`                // index of the least similar file, -1 if there is none
+
+	pairIndex map[[2]int]int // (a, b) to position in Pairs
+}
+
+// Differs reports whether the files do not all share one content
+func (m *DiffMatrix) Differs() bool {
+	return m.Distinct > 1
+}
+
+// pair returns the comparison of files i and j
+func (m *DiffMatrix) pair(i, j int) MatrixPair {
+	if i > j {
+		i, j = j, i
+	}
+	if k, ok := m.pairIndex[[2]int{i, j}]; ok {
+		return m.Pairs[k]
+	}
+	return MatrixPair{}
+}
+
+// CompareMany hashes every file and compares each pair by size, fuzzy hash and bytes.
+// Files with the same SHA-256 share one content, so the fuzzy and byte comparisons
+// run once per pair of distinct contents rather than once per pair of files.
+func CompareMany(paths []string) (*DiffMatrix, error) {
+	m := &DiffMatrix{Groups: [][]int{}, Outlier: -1, pairIndex: make(map[[2]int]int)}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("%s is a directory; only two directories can be compared", path)
+		}
+		hashes, err := calculateHashes(HashContext, path, true)
+		if err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, MatrixFile{Path: path, Size: info.Size(), SHA256: hashes.SHA256, SSDeep: hashes.SSDeep})
+	}
+
+	// Identical groups, in order of first appearance
+	groupOf := make(map[string]int)
+	var groups [][]int
+	fileGroup := make([]int, len(m.Files))
+	for i, f := range m.Files {
+		g, ok := groupOf[f.SHA256]
+		if !ok {
+			g = len(groups)
+			groupOf[f.SHA256] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+		fileGroup[i] = g
+	}
+	m.Distinct = len(groups)
+	for _, g := range groups {
+		if len(g) > 1 {
+			m.Groups = append(m.Groups, g)
+		}
+	}
+
+	// Scores between two distinct contents, keyed by ordered group indexes;
+	// both measures are symmetric
+	type score struct {
+		similarity int
+		byteMatch  float64
+	}
+	scores := make(map[[2]int]score)
+
+	for i := range m.Files {
+		for j := i + 1; j < len(m.Files); j++ {
+			a, b := m.Files[i], m.Files[j]
+			p := MatrixPair{A: i, B: j, SizeDelta: b.Size - a.Size, Identical: a.SHA256 == b.SHA256}
+			if p.Identical {
+				p.Similarity, p.ByteMatch = 100, 100
+			} else {
+				key := [2]int{fileGroup[i], fileGroup[j]}
+				if key[0] > key[1] {
+					key[0], key[1] = key[1], key[0]
+				}
+				s, ok := scores[key]
+				if !ok {
+					s.similarity, _ = FuzzyCompare(a.SSDeep, b.SSDeep)
+					bd, err := DiffBinaryFiles(a.Path, b.Path, DiffOpts.IgnoreVolatile)
+					if err != nil {
+						return nil, err
+					}
+					s.byteMatch = 100 - bd.ChangePercent
+					scores[key] = s
+				}
+				p.Similarity, p.ByteMatch = s.similarity, s.byteMatch
+			}
+			m.pairIndex[[2]int{i, j}] = len(m.Pairs)
+			m.Pairs = append(m.Pairs, p)
+
+			// Identical pairs are already listed as groups
+			if p.Identical {
+				continue
+			}
+			if m.MostSimilar == nil || p.Similarity > m.MostSimilar.Similarity ||
+				(p.Similarity == m.MostSimilar.Similarity && p.ByteMatch > m.MostSimilar.ByteMatch) {
+				best := p
+				m.MostSimilar = &best
+			}
+		}
+	}
+
+	// The outlier has the lowest average similarity to everything else;
+	// a tie means no single file stands out
+	if len(m.Files) >= 3 && m.Differs() {
+		lowest, tied := -1.0, false
+		for i := range m.Files {
+			avg := m.averageSimilarity(i)
+			switch {
+			case lowest < 0 || avg < lowest:
+				lowest, tied = avg, false
+				m.Outlier = i
+			case avg == lowest:
+				tied = true
+			}
+		}
+		if tied {
+			m.Outlier = -1
+		}
+	}
+	return m, nil
+}
+
+// averageSimilarity is the mean score of file i against the others
+func (m *DiffMatrix) averageSimilarity(i int) float64 {
+	total := 0
+	for j := range m.Files {
+		if i != j {
+			total += m.pair(i, j).Similarity
+		}
+	}
+	return float64(total) / float64(len(m.Files)-1)
+}
+
+// FormatDiffMatrixJSON renders a matrix as JSON; brief output fits on one line and omits pairs
+func FormatDiffMatrixJSON(m *DiffMatrix, brief bool) (string, error) {
+	var data []byte
+	var err error
+	if brief {
+		short := *m
+		short.Pairs = nil
+		data, err = json.Marshal(short)
+	} else {
+		data, err = json.MarshalIndent(m, "", "  ")
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// FormatDiffMatrixBrief renders a matrix as a single line
+func FormatDiffMatrixBrief(m *DiffMatrix) string {
+	if !m.Differs() {
+		return fmt.Sprintf("All %d files are identical\n", len(m.Files))
+	}
+	line := fmt.Sprintf("%d files, %d distinct", len(m.Files), m.Distinct)
+	if p := m.MostSimilar; p != nil {
+		line += fmt.Sprintf("; most similar %s and %s (%d/100)", m.Files[p.A].Path, m.Files[p.B].Path, p.Similarity)
+	}
+	if m.Outlier >= 0 {
+		line += fmt.Sprintf("; outlier %s", m.Files[m.Outlier].Path)
+	}
+	return line + "\n"
+}
+
+// FormatDiffMatrix renders a many-file comparison with colors
+func FormatDiffMatrix(m *DiffMatrix, labelFn, treeFn, matchFn, diffFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	var names []string
+	for _, f := range m.Files {
+		names = append(names, filepath.Base(f.Path))
+	}
+	fmt.Fprintf(&sb, "\n%s\n", labelFn(fmt.Sprintf("diff %s (%d files)", strings.Join(names, " "), len(m.Files))))
+
+	ref := func(i int) string { return fmt.Sprintf("[%d]", i+1) }
+
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Files:"))
+	for i, f := range m.Files {
+		branch := "├─"
+		if i == len(m.Files)-1 {
+			branch = "╰─"
+		}
+		fmt.Fprintf(&sb, "  %s %s %s %s %s\n", treeFn(branch), labelFn(ref(i)), valueFn(f.Path),
+			treeFn(fmt.Sprintf("%d bytes", f.Size)), treeFn(f.SHA256[:12]))
+	}
+
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Identical:"))
+	if len(m.Groups) == 0 {
+		fmt.Fprintf(&sb, "  %s %s\n", diffFn("✗"), valueFn("No two files have the same content"))
+	}
+	for _, g := range m.Groups {
+		var refs []string
+		for _, i := range g {
+			refs = append(refs, ref(i))
+		}
+		fmt.Fprintf(&sb, "  %s %s\n", matchFn("✓"), valueFn(strings.Join(refs, " = ")))
+	}
+
+	// Fuzzy similarity matrix
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Similarity (0-100):"))
+	width := len(ref(len(m.Files)-1)) + 1
+	sb.WriteString("  " + strings.Repeat(" ", width))
+	for i := range m.Files {
+		fmt.Fprintf(&sb, "%*s", width+1, ref(i))
+	}
+	sb.WriteString("\n")
+	for i := range m.Files {
+		fmt.Fprintf(&sb, "  %-*s", width, ref(i))
+		for j := range m.Files {
+			cell := fmt.Sprintf("%*s", width+1, "-")
+			if i != j {
+				score := m.pair(i, j).Similarity
+				cell = fmt.Sprintf("%*d", width+1, score)
+				if score >= 50 {
+					cell = matchFn(cell)
+				} else {
+					cell = diffFn(cell)
+				}
+			} else {
+				cell = treeFn(cell)
+			}
+			sb.WriteString(cell)
+		}
+		sb.WriteString("\n")
+	}
+
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Pairs:"))
+	for k, p := range m.Pairs {
+		branch := "├─"
+		if k == len(m.Pairs)-1 {
+			branch = "╰─"
+		}
+		fmt.Fprintf(&sb, "  %s %s  size %s  fuzzy %s  bytes %s\n", treeFn(branch),
+			labelFn(ref(p.A)+" ↔ "+ref(p.B)),
+			valueFn(fmt.Sprintf("%+d", p.SizeDelta)),
+			valueFn(fmt.Sprintf("%3d", p.Similarity)),
+			valueFn(fmt.Sprintf("%.2f%%", p.ByteMatch)))
+	}
+
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Ranking:"))
+	if p := m.MostSimilar; p != nil {
+		fmt.Fprintf(&sb, "  %s Most similar : %s %s\n", matchFn("★"),
+			matchFn(m.Files[p.A].Path+" ↔ "+m.Files[p.B].Path),
+			valueFn(fmt.Sprintf("(%d/100, %.2f%% bytes)", p.Similarity, p.ByteMatch)))
+	}
+	if m.Outlier >= 0 {
+		fmt.Fprintf(&sb, "  %s Outlier      : %s %s\n", diffFn("!"),
+			diffFn(m.Files[m.Outlier].Path),
+			valueFn(fmt.Sprintf("(average %.0f/100)", m.averageSimilarity(m.Outlier))))
+	}
+
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Verdict:"))
+	if m.Differs() {
+		fmt.Fprintf(&sb, "  %s\n", diffFn(fmt.Sprintf("✗ Files are DIFFERENT (%d distinct contents among %d files)", m.Distinct, len(m.Files))))
+	} else {
+		fmt.Fprintf(&sb, "  %s\n", matchFn(fmt.Sprintf("✓ All %d files are IDENTICAL", len(m.Files))))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Package manager databases, relative to the root they describe
+const (
+	dpkgStatus    = "/var/lib/dpkg/status"
+	dpkgStatusD   = "/var/lib/dpkg/status.d" // distroless images: one stanza per file, no .list files
+	dpkgInfo      = "/var/lib/dpkg/info"
+	pacmanLocalDB = "/var/lib/pacman/local"
+)
+
+// apkInstalledDBs are tried in order; newer apk-tools moved the database under /usr
+var apkInstalledDBs = []string{"/lib/apk/db/installed", "/usr/lib/apk/db/installed"}
+
+// InstalledPackage is one package recorded in a package manager's database
+type InstalledPackage struct {
+	Manager   string // dpkg, apk or pacman
+	Name      string
+	Version   string
+	Arch      string
+	Files     []string        // absolute paths as recorded; dpkg lists include directories
+	Conffiles map[string]bool // files the package manager preserves across upgrades
+	Source    string          // database file listing the package's files
+
+	digests     map[string]PackageDigest
+	loadDigests func() map[string]PackageDigest // reads the checksum file on first use
+	digestsOnce sync.Once
+}
+
+// PackageDigest is the checksum a package manager recorded for a file at install time
+type PackageDigest struct {
+	Algorithm string // md5, sha1 or sha256
+	Sum       string // hex
+}
+
+// Digests returns the recorded checksum of each file that has one. dpkg and
+// pacman keep them in separate files, which are only read when needed.
+func (p *InstalledPackage) Digests() map[string]PackageDigest {
+	p.digestsOnce.Do(func() {
+		if p.digests == nil {
+			p.digests = make(map[string]PackageDigest)
+		}
+		if p.loadDigests != nil {
+			for file, d := range p.loadDigests() {
+				p.digests[file] = d
+			}
+		}
+	})
+	return p.digests
+}
+
+// PackageOwner is a package that installed a file
+type PackageOwner struct {
+	*InstalledPackage
+	File     string // the path as recorded in the database
+	Conffile bool
+	Check    *FileCheck // the file against its recorded checksum
+}
+
+// packageIndex maps recorded paths to the packages that list them
+type packageIndex struct {
+	packages []*InstalledPackage
+	owners   map[string][]*InstalledPackage
+}
+
+// packageIndexes caches the databases read per root
+var (
+	packageIndexes   = make(map[string]*packageIndex)
+	packageIndexesMu sync.Mutex
+)
+
+// InstalledPackages reads the dpkg, apk and pacman databases under root
+func InstalledPackages(root string) []*InstalledPackage {
+	return loadPackageIndex(root).packages
+}
+
+func loadPackageIndex(root string) *packageIndex {
+	packageIndexesMu.Lock()
+	defer packageIndexesMu.Unlock()
+	if idx, ok := packageIndexes[root]; ok {
+		return idx
+	}
+
+	idx := &packageIndex{owners: make(map[string][]*InstalledPackage)}
+	idx.packages = append(idx.packages, readDpkgPackages(root)...)
+	idx.packages = append(idx.packages, readApkPackages(root)...)
+	idx.packages = append(idx.packages, readPacmanPackages(root)...)
+	for _, p := range idx.packages {
+		for _, f := range p.Files {
+			idx.owners[f] = append(idx.owners[f], p)
+		}
+	}
+	packageIndexes[root] = idx
+	return idx
+}
+
+// FindPackageOwners returns the packages whose database lists the file at
+// filePath, a path on the host inside root, and checks the file against the
+// checksum each recorded. Directories are shared by many packages and are not
+// looked up.
+func FindPackageOwners(root, filePath string) []*PackageOwner {
+	if info, err := os.Lstat(filePath); err != nil || info.IsDir() {
+		return nil
+	}
+	idx := loadPackageIndex(root)
+	if len(idx.packages) == 0 {
+		return nil
+	}
+
+	var owners []*PackageOwner
+	seen := make(map[*InstalledPackage]bool)
+	for _, candidate := range packagePathCandidates(root, filePath) {
+		for _, p := range idx.owners[candidate] {
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+			owners = append(owners, &PackageOwner{
+				InstalledPackage: p,
+				File:             candidate,
+				Conffile:         p.Conffiles[candidate],
+				Check:            CheckPackageFile(p, candidate, filePath),
+			})
+		}
+	}
+	return owners
+}
+
+// packagePathCandidates lists the names a database may record a file under:
+// the path itself, the path with symlinked directories resolved, and the
+// /usr-merged and unmerged spellings of both (/bin/ls and /usr/bin/ls)
+func packagePathCandidates(root, filePath string) []string {
+	var candidates []string
+	add := func(p string) {
+		rel, ok := pathInRoot(root, p)
+		if !ok {
+			return
+		}
+		for _, c := range []string{rel, usrMergeAlias(rel)} {
+			if c != "" && !containsString(candidates, c) {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	add(filePath)
+	if dir, err := evalSymlinks(filepath.Dir(filePath)); err == nil {
+		add(filepath.Join(dir, filepath.Base(filePath)))
+	}
+	return candidates
+}
+
+// pathInRoot returns p as an absolute path inside root, in slash form
+func pathInRoot(root, p string) (string, bool) {
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return "/" + filepath.ToSlash(rel), true
+}
+
+// usrMergeAlias returns the other spelling of a path under a directory that
+// /usr merge turned into a symlink, or ""
+func usrMergeAlias(p string) string {
+	for _, dir := range []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32"} {
+		switch {
+		case strings.HasPrefix(p, dir+"/"):
+			return "/usr" + p
+		case strings.HasPrefix(p, "/usr"+dir+"/"):
+			return strings.TrimPrefix(p, "/usr")
+		}
+	}
+	return ""
+}
+
+// readStanzas parses deb822 control paragraphs ("Field: value", continuation
+// lines indented) such as the dpkg status file
+func readStanzas(file string) []map[string]string {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	var stanzas []map[string]string
+	stanza := make(map[string]string)
+	last := ""
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			if len(stanza) > 0 {
+				stanzas = append(stanzas, stanza)
+				stanza = make(map[string]string)
+			}
+		case line[0] == ' ' || line[0] == '\t':
+			if last != "" {
+				stanza[last] += "\n" + strings.TrimSpace(line)
+			}
+		default:
+			key, value, ok := strings.Cut(line, ":")
+			if ok {
+				last = key
+				stanza[key] = strings.TrimSpace(value)
+			}
+		}
+	}
+	if len(stanza) > 0 {
+		stanzas = append(stanzas, stanza)
+	}
+	return stanzas
+}
+
+// readDpkgPackages reads the installed packages from the dpkg status file and
+// their file lists from info/*.list; distroless status.d entries list their
+// files through the md5sums file alongside instead
+func readDpkgPackages(root string) []*InstalledPackage {
+	var packages []*InstalledPackage
+	for _, st := range readStanzas(filepath.Join(root, dpkgStatus)) {
+		// "install ok installed"; config-files and half-installed packages own no files
+		if status := strings.Fields(st["Status"]); len(status) != 3 || status[2] != "installed" {
+			continue
+		}
+		p := dpkgPackage(st)
+		for _, name := range []string{p.Name + ":" + p.Arch, p.Name} {
+			list := filepath.Join(root, dpkgInfo, name+".list")
+			if files, err := readLines(list); err == nil {
+				p.Source = list
+				p.Files = files
+				sums := strings.TrimSuffix(list, ".list") + ".md5sums"
+				p.loadDigests = func() map[string]PackageDigest { return readMd5sums(sums) }
+				break
+			}
+		}
+		packages = append(packages, p)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(root, dpkgStatusD))
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), ".md5sums") {
+			continue
+		}
+		for _, st := range readStanzas(filepath.Join(root, dpkgStatusD, e.Name())) {
+			p := dpkgPackage(st)
+			sums := filepath.Join(root, dpkgStatusD, e.Name()+".md5sums")
+			if _, err := os.Stat(sums); err == nil {
+				p.Source = sums
+			}
+			for file, d := range readMd5sums(sums) {
+				p.Files = append(p.Files, file)
+				p.digests[file] = d
+			}
+			sort.Strings(p.Files)
+			packages = append(packages, p)
+		}
+	}
+	return packages
+}
+
+// dpkgPackage builds a package from its status stanza
+func dpkgPackage(st map[string]string) *InstalledPackage {
+	p := &InstalledPackage{
+		Manager:   "dpkg",
+		Name:      st["Package"],
+		Version:   st["Version"],
+		Arch:      st["Architecture"],
+		Conffiles: make(map[string]bool),
+		digests:   make(map[string]PackageDigest),
+	}
+	// Conffiles lines are "/etc/foo <md5> [obsolete]"; md5sums leaves them out
+	for _, line := range strings.Split(st["Conffiles"], "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 {
+			p.Conffiles[fields[0]] = true
+			p.digests[fields[0]] = PackageDigest{Algorithm: "md5", Sum: fields[1]}
+		}
+	}
+	return p
+}
+
+// readMd5sums parses a dpkg md5sums file: "<md5>  usr/bin/foo" per line
+func readMd5sums(file string) map[string]PackageDigest {
+	lines, _ := readLines(file)
+	digests := make(map[string]PackageDigest)
+	for _, line := range lines {
+		if sum, name, ok := strings.Cut(line, "  "); ok {
+			digests["/"+strings.TrimPrefix(name, "/")] = PackageDigest{Algorithm: "md5", Sum: sum}
+		}
+	}
+	return digests
+}
+
+// readApkPackages reads Alpine's installed database: one "X:value" line per
+// field, P (name), V (version), A (arch), F (directory) and R (file in the last
+// directory) followed by Z (its checksum), with a blank line between
+// packages. apk has no conffile list;
+// files under /etc are protected from overwriting instead.
+func readApkPackages(root string) []*InstalledPackage {
+	for _, db := range apkInstalledDBs {
+		f, err := os.Open(filepath.Join(root, db))
+		if err != nil {
+			continue
+		}
+		defer func() { _ = f.Close() }()
+
+		var packages []*InstalledPackage
+		var p *InstalledPackage
+		dir, file := "", ""
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(scanner.Text(), ":")
+			if !ok || len(key) != 1 {
+				p = nil
+				continue
+			}
+			if p == nil {
+				p = &InstalledPackage{
+					Manager:   "apk",
+					Source:    filepath.Join(root, db),
+					Conffiles: make(map[string]bool),
+					digests:   make(map[string]PackageDigest),
+				}
+				packages = append(packages, p)
+				dir = ""
+			}
+			switch key {
+			case "P":
+				p.Name = value
+			case "V":
+				p.Version = value
+			case "A":
+				p.Arch = value
+			case "F":
+				dir = value
+			case "R":
+				file = path.Join("/", dir, value)
+				p.Files = append(p.Files, file)
+				if strings.HasPrefix(file, "/etc/") {
+					p.Conffiles[file] = true
+				}
+			case "Z":
+				if d, ok := apkDigest(value); ok && file != "" {
+					p.digests[file] = d
+				}
+			}
+		}
+		return packages
+	}
+	return nil
+}
+
+// apkDigest decodes an apk checksum: "Q1" and base64 of the SHA1
+func apkDigest(value string) (PackageDigest, bool) {
+	if !strings.HasPrefix(value, "Q1") {
+		return PackageDigest{}, false
+	}
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, "Q1"))
+	if err != nil {
+		return PackageDigest{}, false
+	}
+	return PackageDigest{Algorithm: "sha1", Sum: hex.EncodeToString(sum)}, true
+}
+
+// readPacmanPackages reads pacman's local database: a directory per package
+// holding %SECTION% blocks in desc (name, version, arch) and files (the file
+// list and %BACKUP%, the conffiles); checksums are in the gzipped mtree file
+func readPacmanPackages(root string) []*InstalledPackage {
+	dbDir := filepath.Join(root, pacmanLocalDB)
+	entries, err := os.ReadDir(dbDir)
+	if err != nil {
+		return nil
+	}
+	var packages []*InstalledPackage
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		desc := readPacmanSections(filepath.Join(dbDir, e.Name(), "desc"))
+		if len(desc["NAME"]) == 0 {
+			continue
+		}
+		filesPath := filepath.Join(dbDir, e.Name(), "files")
+		files := readPacmanSections(filesPath)
+		p := &InstalledPackage{
+			Manager:   "pacman",
+			Name:      desc["NAME"][0],
+			Source:    filesPath,
+			Conffiles: make(map[string]bool),
+		}
+		if v := desc["VERSION"]; len(v) > 0 {
+			p.Version = v[0]
+		}
+		if a := desc["ARCH"]; len(a) > 0 {
+			p.Arch = a[0]
+		}
+		mtree := filepath.Join(dbDir, e.Name(), "mtree")
+		p.loadDigests = func() map[string]PackageDigest { return readMtree(mtree) }
+		for _, f := range files["FILES"] {
+			// Directories end in a slash
+			if !strings.HasSuffix(f, "/") {
+				p.Files = append(p.Files, "/"+f)
+			}
+		}
+		// Backup lines are "etc/foo\t<md5>"
+		for _, b := range files["BACKUP"] {
+			file, _, _ := strings.Cut(b, "\t")
+			p.Conffiles["/"+file] = true
+		}
+		packages = append(packages, p)
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return packages
+}
+
+// readMtree parses the checksums of regular files in a gzipped mtree(5) spec
+// such as pacman writes: "./usr/bin/foo type=file sha256digest=..." lines,
+// with "/set" lines giving defaults for the lines that follow
+func readMtree(file string) map[string]PackageDigest {
+	digests := make(map[string]PackageDigest)
+	f, err := os.Open(file)
+	if err != nil {
+		return digests
+	}
+	defer func() { _ = f.Close() }()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return digests
+	}
+	defer func() { _ = zr.Close() }()
+
+	defaults := make(map[string]string)
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		keywords := make(map[string]string)
+		for k, v := range defaults {
+			keywords[k] = v
+		}
+		for _, kw := range fields[1:] {
+			k, v, _ := strings.Cut(kw, "=")
+			keywords[k] = v
+		}
+		switch fields[0] {
+		case "/set":
+			defaults = keywords
+			continue
+		case "/unset":
+			for _, k := range fields[1:] {
+				delete(defaults, k)
+			}
+			continue
+		}
+		// pacman's own metadata files (.PKGINFO, .MTREE) are not installed
+		name := unescapeMtree(fields[0])
+		if !strings.HasPrefix(name, "./") || strings.HasPrefix(name, "./.") || keywords["type"] != "file" {
+			continue
+		}
+		switch {
+		case keywords["sha256digest"] != "":
+			digests[strings.TrimPrefix(name, ".")] = PackageDigest{Algorithm: "sha256", Sum: keywords["sha256digest"]}
+		case keywords["md5digest"] != "":
+			digests[strings.TrimPrefix(name, ".")] = PackageDigest{Algorithm: "md5", Sum: keywords["md5digest"]}
+		}
+	}
+	return digests
+}
+
+// unescapeMtree decodes the \ooo octal escapes mtree uses for spaces and
+// other special characters in file names
+func unescapeMtree(name string) string {
+	if !strings.Contains(name, "\\") {
+		return name
+	}
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) {
+			var c byte
+			valid := true
+			for _, d := range name[i+1 : i+4] {
+				if d < '0' || d > '7' {
+					valid = false
+					break
+				}
+				c = c*8 + byte(d-'0')
+			}
+			if valid {
+				sb.WriteByte(c)
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(name[i])
+	}
+	return sb.String()
+}
+
+// readPacmanSections parses "%NAME%" headers followed by value lines
+func readPacmanSections(file string) map[string][]string {
+	lines, err := readLines(file)
+	if err != nil {
+		return nil
+	}
+	sections := make(map[string][]string)
+	section := ""
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "%") && strings.HasSuffix(line, "%") && len(line) > 2:
+			section = strings.Trim(line, "%")
+		case section != "":
+			sections[section] = append(sections[section], line)
+		}
+	}
+	return sections
+}
+
+// readLines returns the non-empty lines of a file
+func readLines(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// FormatPackageOwner renders the package that installed a file
+func FormatPackageOwner(o *PackageOwner, treeFn, pathFn, warnFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	conffile := "no"
+	switch {
+	case o.Conffile && o.Manager == "apk":
+		conffile = "yes (protected path)"
+	case o.Conffile:
+		conffile = "yes"
+	}
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Name     :"), pathFn(o.Name))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Version  :"), valueFn(orNone(o.Version)))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Arch     :"), valueFn(orNone(o.Arch)))
+	fmt.Fprintf(&sb, "  %s %s %s %s\n", treeFn("├─"), treeFn("Manager  :"), valueFn(o.Manager), treeFn("("+o.Source+")"))
+	if o.Check == nil || o.Check.Status == CheckUnchecked {
+		fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("╰─"), treeFn("Conffile :"), valueFn(conffile))
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Conffile :"), valueFn(conffile))
+	if o.Check.Status != CheckModified {
+		fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("╰─"), treeFn("Verify   :"), formatCheck(o.Check, treeFn, pathFn, warnFn, valueFn))
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Verify   :"), formatCheck(o.Check, treeFn, pathFn, warnFn, valueFn))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Expected :"), valueFn(o.Check.Expected.Algorithm+" "+o.Check.Expected.Sum))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("╰─"), treeFn("Actual   :"), warnFn(o.Check.Expected.Algorithm+" "+o.Check.Actual))
+	return sb.String()
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// findPackage returns the package of that name read from root
+func findPackage(t *testing.T, root, name string) *InstalledPackage {
+	t.Helper()
+	for _, p := range InstalledPackages(root) {
+		if p.Name == name {
+			return p
+		}
+	}
+	t.Fatalf("package %s not found under %s", name, root)
+	return nil
+}
+
+func TestReadDpkgPackages(t *testing.T) {
+	root := filepath.Join("testdata", "dpkg")
+	packages := readDpkgPackages(root)
+	if len(packages) != 1 {
+		t.Fatalf("got %d packages, want 1 (config-files packages own nothing)", len(packages))
+	}
+
+	p := packages[0]
+	if p.Manager != "dpkg" || p.Name != "hello" || p.Version != "2.10-3" || p.Arch != "amd64" {
+		t.Errorf("got %s %s %s %s, want dpkg hello 2.10-3 amd64", p.Manager, p.Name, p.Version, p.Arch)
+	}
+	if want := filepath.Join(root, dpkgInfo, "hello.list"); p.Source != want {
+		t.Errorf("Source = %s, want %s", p.Source, want)
+	}
+	wantFiles := []string{"/.", "/etc", "/etc/hello.conf", "/usr", "/usr/bin", "/usr/bin/hello"}
+	if !slices.Equal(p.Files, wantFiles) {
+		t.Errorf("Files = %v, want %v", p.Files, wantFiles)
+	}
+	if !p.Conffiles["/etc/hello.conf"] || !p.Conffiles["/etc/hello.old"] || p.Conffiles["/usr/bin/hello"] {
+		t.Errorf("Conffiles = %v, want /etc/hello.conf and /etc/hello.old", p.Conffiles)
+	}
+
+	// md5sums covers regular files, the Conffiles field covers conffiles
+	digests := p.Digests()
+	want := map[string]PackageDigest{
+		"/usr/bin/hello":  {Algorithm: "md5", Sum: "b1946ac92492d2347c6235b4d2611184"},
+		"/etc/hello.conf": {Algorithm: "md5", Sum: "eff5bc1ef8ec9d03e640fc4370f5eacd"},
+		"/etc/hello.old":  {Algorithm: "md5", Sum: "0f343b0931126a20f133d67c2b018a3b"},
+	}
+	for file, d := range want {
+		if digests[file] != d {
+			t.Errorf("digest of %s = %+v, want %+v", file, digests[file], d)
+		}
+	}
+}
+
+func TestReadDpkgStatusD(t *testing.T) {
+	root := filepath.Join("testdata", "distroless")
+	p := findPackage(t, root, "tzdata")
+	if p.Version != "2024a-0+deb12u1" || p.Arch != "all" {
+		t.Errorf("got %s %s, want 2024a-0+deb12u1 all", p.Version, p.Arch)
+	}
+	if want := filepath.Join(root, dpkgStatusD, "tzdata.md5sums"); p.Source != want {
+		t.Errorf("Source = %s, want %s", p.Source, want)
+	}
+	wantFiles := []string{"/usr/share/zoneinfo/Etc/UTC", "/usr/share/zoneinfo/UTC"}
+	if !slices.Equal(p.Files, wantFiles) {
+		t.Errorf("Files = %v, want %v", p.Files, wantFiles)
+	}
+	if d := p.Digests()["/usr/share/zoneinfo/UTC"]; d.Sum != "b1946ac92492d2347c6235b4d2611184" {
+		t.Errorf("digest of /usr/share/zoneinfo/UTC = %+v", d)
+	}
+}
+
+func TestReadMd5sums(t *testing.T) {
+	digests := readMd5sums(filepath.Join("testdata", "dpkg", dpkgInfo, "hello.md5sums"))
+	if len(digests) != 1 || digests["/usr/bin/hello"].Sum != "b1946ac92492d2347c6235b4d2611184" {
+		t.Errorf("got %v", digests)
+	}
+	if digests := readMd5sums(filepath.Join("testdata", "missing.md5sums")); len(digests) != 0 {
+		t.Errorf("missing file gave %v", digests)
+	}
+}
+
+func TestReadApkPackages(t *testing.T) {
+	root := filepath.Join("testdata", "apk")
+	packages := readApkPackages(root)
+	if len(packages) != 2 {
+		t.Fatalf("got %d packages, want 2", len(packages))
+	}
+
+	busybox := packages[0]
+	if busybox.Name != "busybox" || busybox.Version != "1.36.1-r5" || busybox.Arch != "x86_64" {
+		t.Errorf("got %s %s %s, want busybox 1.36.1-r5 x86_64", busybox.Name, busybox.Version, busybox.Arch)
+	}
+	wantFiles := []string{"/bin/busybox", "/etc/securetty", "/etc/motd"}
+	if !slices.Equal(busybox.Files, wantFiles) {
+		t.Errorf("Files = %v, want %v", busybox.Files, wantFiles)
+	}
+	if !busybox.Conffiles["/etc/securetty"] || busybox.Conffiles["/bin/busybox"] {
+		t.Errorf("Conffiles = %v, want the files under /etc", busybox.Conffiles)
+	}
+
+	// Q1 checksums are base64 SHA1
+	digests := busybox.Digests()
+	if d := digests["/bin/busybox"]; d != (PackageDigest{Algorithm: "sha1", Sum: "f572d396fae9206628714fb2ce00f72e94f2258f"}) {
+		t.Errorf("digest of /bin/busybox = %+v", d)
+	}
+	if _, ok := digests["/etc/motd"]; ok {
+		t.Error("/etc/motd has no Z line but got a digest")
+	}
+
+	musl := packages[1]
+	if musl.Name != "musl" || !slices.Equal(musl.Files, []string{"/lib/ld-musl-x86_64.so.1"}) {
+		t.Errorf("got %s %v", musl.Name, musl.Files)
+	}
+	if len(musl.Digests()) != 0 {
+		t.Errorf("a checksum without the Q1 prefix was decoded: %v", musl.Digests())
+	}
+}
+
+func TestApkDigest(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"Q19XLTlvrpIGYocU+yzgD3LpTyJY8=", "f572d396fae9206628714fb2ce00f72e94f2258f", true},
+		{"Q1not-base64!", "", false},
+		{"X19XLTlvrpIGYocU+yzgD3LpTyJY8=", "", false},
+	}
+	for _, tt := range tests {
+		d, ok := apkDigest(tt.value)
+		if ok != tt.ok || d.Sum != tt.want {
+			t.Errorf("apkDigest(%q) = %+v, %v; want %s, %v", tt.value, d, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReadPacmanPackages(t *testing.T) {
+	root := filepath.Join("testdata", "pacman")
+	packages := readPacmanPackages(root)
+	if len(packages) != 1 {
+		t.Fatalf("got %d packages, want 1", len(packages))
+	}
+
+	p := packages[0]
+	if p.Manager != "pacman" || p.Name != "hello" || p.Version != "2.12-1" || p.Arch != "x86_64" {
+		t.Errorf("got %s %s %s %s, want pacman hello 2.12-1 x86_64", p.Manager, p.Name, p.Version, p.Arch)
+	}
+	wantFiles := []string{"/etc/hello.conf", "/usr/bin/hello", "/usr/share/doc/hello/read me"}
+	if !slices.Equal(p.Files, wantFiles) {
+		t.Errorf("Files = %v, want %v (directories left out)", p.Files, wantFiles)
+	}
+	if !p.Conffiles["/etc/hello.conf"] || len(p.Conffiles) != 1 {
+		t.Errorf("Conffiles = %v, want /etc/hello.conf", p.Conffiles)
+	}
+}
+
+func TestReadMtree(t *testing.T) {
+	digests := readMtree(filepath.Join("testdata", "pacman", pacmanLocalDB, "hello-2.12-1", "mtree"))
+	want := map[string]PackageDigest{
+		"/etc/hello.conf":              {Algorithm: "sha256", Sum: "dc51b8c96c2d745df3bd5590d990230a482fd247123599548e0632fdbf97fc22"},
+		"/usr/bin/hello":               {Algorithm: "sha256", Sum: "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"},
+		"/usr/share/doc/hello/read me": {Algorithm: "md5", Sum: "eff5bc1ef8ec9d03e640fc4370f5eacd"},
+	}
+	if len(digests) != len(want) {
+		t.Errorf("got %d digests, want %d (no directories, links or metadata files): %v", len(digests), len(want), digests)
+	}
+	for file, d := range want {
+		if digests[file] != d {
+			t.Errorf("digest of %s = %+v, want %+v", file, digests[file], d)
+		}
+	}
+}
+
+func TestUnescapeMtree(t *testing.T) {
+	tests := map[string]string{
+		"./usr/bin/hello":        "./usr/bin/hello",
+		`./read\040me`:           "./read me",
+		`./tab\011and\040space`:  "./tab\tand space",
+		`./trailing\04`:          `./trailing\04`,
+		`./not\08octal`:          `./not\08octal`,
+		`./back\134slash\040end`: `./back\slash end`,
+		`./ends\040`:             "./ends ",
+	}
+	for in, want := range tests {
+		if got := unescapeMtree(in); got != want {
+			t.Errorf("unescapeMtree(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestUsrMergeAlias(t *testing.T) {
+	tests := map[string]string{
+		"/bin/ls":                 "/usr/bin/ls",
+		"/usr/bin/ls":             "/bin/ls",
+		"/sbin/init":              "/usr/sbin/init",
+		"/lib/x86_64-linux-gnu/x": "/usr/lib/x86_64-linux-gnu/x",
+		"/usr/lib64/ld.so":        "/lib64/ld.so",
+		"/usr/share/doc":          "",
+		"/etc/passwd":             "",
+		"/binaries/x":             "",
+		"/usr/libexec/x":          "",
+	}
+	for in, want := range tests {
+		if got := usrMergeAlias(in); got != want {
+			t.Errorf("usrMergeAlias(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFindPackageOwners(t *testing.T) {
+	root := filepath.Join("testdata", "dpkg")
+
+	// Recorded as /usr/bin/hello, found through its /usr-merged spelling
+	owners := FindPackageOwners(root, filepath.Join(root, "bin", "hello"))
+	if len(owners) != 1 {
+		t.Fatalf("got %d owners of /bin/hello, want 1", len(owners))
+	}
+	o := owners[0]
+	if o.Name != "hello" || o.File != "/usr/bin/hello" || o.Conffile {
+		t.Errorf("got %s %s conffile=%v, want hello /usr/bin/hello", o.Name, o.File, o.Conffile)
+	}
+	if o.Check.Status != CheckOK {
+		t.Errorf("check of /bin/hello = %s, want %s", o.Check.Status, CheckOK)
+	}
+
+	// The conffile was edited after installation
+	owners = FindPackageOwners(root, filepath.Join(root, "etc", "hello.conf"))
+	if len(owners) != 1 || !owners[0].Conffile || owners[0].Check.Status != CheckModified {
+		t.Errorf("got %+v, want a modified conffile of hello", owners)
+	}
+
+	if owners := FindPackageOwners(root, filepath.Join(root, "etc")); owners != nil {
+		t.Errorf("directories should not be looked up, got %+v", owners)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// PkgConfig is a parsed pkg-config .pc file
+type PkgConfig struct {
+	Module          string // file name without .pc, as passed to pkg-config
+	Path            string
+	Name            string
+	Description     string
+	Version         string
+	Cflags          string
+	Libs            string
+	LibsPrivate     string
+	Requires        string
+	RequiresPrivate string
+	ShadowedBy      string // an earlier .pc file with the same module name
+	Vars            map[string]string
+}
+
+// PkgConfigDirs returns the directories pkg-config searches, in order:
+// PKG_CONFIG_PATH, then PKG_CONFIG_LIBDIR or the built-in defaults. Inside a
+// root only the defaults are used.
+func PkgConfigDirs() []string {
+	dirs := hostEnvList("PKG_CONFIG_PATH")
+	if libdir, ok := os.LookupEnv("PKG_CONFIG_LIBDIR"); ok && sysroot == "" {
+		return append(dirs, filepath.SplitList(libdir)...)
+	}
+	defaults := defaultPkgConfigDirs()
+	for i, dir := range defaults {
+		defaults[i] = rootRealPath(dir)
+	}
+	return append(dirs, defaults...)
+}
+
+// defaultPkgConfigDirs are pkg-config's built-in search directories
+func defaultPkgConfigDirs() []string {
+	var dirs []string
+	if triplet, ok := multiarchTriplets[runtime.GOARCH]; ok {
+		dirs = append(dirs,
+			"/usr/local/lib/"+triplet+"/pkgconfig",
+			"/usr/lib/"+triplet+"/pkgconfig",
+		)
+	}
+	return append(dirs,
+		"/usr/local/lib/pkgconfig",
+		"/usr/local/share/pkgconfig",
+		"/usr/lib64/pkgconfig",
+		"/usr/lib/pkgconfig",
+		"/usr/share/pkgconfig",
+		"/opt/homebrew/lib/pkgconfig",
+		"/opt/homebrew/share/pkgconfig",
+	)
+}
+
+// FindPkgConfigs parses every .pc file in the search directories. Later files
+// with the same module name are kept but marked as shadowed, as pkg-config
+// only ever uses the first.
+func FindPkgConfigs() []*PkgConfig {
+	var pcs []*PkgConfig
+	first := make(map[string]string)
+	seenDirs := make(map[string]bool)
+	for _, dir := range PkgConfigDirs() {
+		real, err := evalSymlinks(dir)
+		if err != nil || seenDirs[real] {
+			continue
+		}
+		seenDirs[real] = true
+
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.pc"))
+		for _, path := range matches {
+			pc, err := ParsePkgConfig(path)
+			if err != nil {
+				continue
+			}
+			if earlier, ok := first[pc.Module]; ok {
+				pc.ShadowedBy = earlier
+			} else {
+				first[pc.Module] = path
+			}
+			pcs = append(pcs, pc)
+		}
+	}
+	return pcs
+}
+
+// ParsePkgConfig reads a .pc file natively: "name=value" lines define
+// variables, "Key: value" lines are fields, and ${name} is expanded in both
+func ParsePkgConfig(path string) (*PkgConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	pc := &PkgConfig{
+		Module: strings.TrimSuffix(filepath.Base(path), ".pc"),
+		Path:   path,
+		Vars:   map[string]string{"pcfiledir": filepath.Dir(path)},
+	}
+	fields := map[string]*string{
+		"name":             &pc.Name,
+		"description":      &pc.Description,
+		"version":          &pc.Version,
+		"cflags":           &pc.Cflags,
+		"libs":             &pc.Libs,
+		"libs.private":     &pc.LibsPrivate,
+		"requires":         &pc.Requires,
+		"requires.private": &pc.RequiresPrivate,
+	}
+
+	scanner := bufio.NewScanner(f)
+	pending := ""
+	for scanner.Scan() {
+		line := pending + scanner.Text()
+		pending = ""
+		// A trailing backslash continues the line
+		if strings.HasSuffix(line, "\\") {
+			pending = strings.TrimSuffix(line, "\\")
+			continue
+		}
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+
+		// Whichever of '=' and ':' comes first decides the kind of line
+		eq, colon := strings.Index(line, "="), strings.Index(line, ":")
+		switch {
+		case eq > 0 && (colon < 0 || eq < colon):
+			name := strings.TrimSpace(line[:eq])
+			pc.Vars[name] = pc.expand(strings.TrimSpace(line[eq+1:]))
+		case colon > 0:
+			key := strings.ToLower(strings.TrimSpace(line[:colon]))
+			if field, ok := fields[key]; ok {
+				*field = strings.Join(strings.Fields(pc.expand(line[colon+1:])), " ")
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if pc.Name == "" {
+		return nil, fmt.Errorf("%s: missing Name field", path)
+	}
+	return pc, nil
+}
+
+// expand substitutes ${name} references with variables defined so far
+func (pc *PkgConfig) expand(value string) string {
+	var sb strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(value[start:], "}")
+		if end < 0 {
+			break
+		}
+		sb.WriteString(value[:start])
+		sb.WriteString(pc.Vars[value[start+2:start+end]])
+		value = value[start+end+1:]
+	}
+	sb.WriteString(value)
+	return sb.String()
+}
+
+// linkNames returns the -l names and -L directories in Libs. The first -l
+// name is the package's own library; the rest are its dependencies.
+func (pc *PkgConfig) linkNames() (libs, dirs []string) {
+	for _, flag := range strings.Fields(pc.Libs) {
+		switch {
+		case strings.HasPrefix(flag, "-l"):
+			libs = append(libs, strings.TrimPrefix(flag, "-l"))
+		case strings.HasPrefix(flag, "-L"):
+			dirs = append(dirs, strings.TrimPrefix(flag, "-L"))
+		}
+	}
+	if libdir := pc.Vars["libdir"]; libdir != "" {
+		dirs = append(dirs, libdir)
+	}
+	return libs, dirs
+}
+
+// ProvidesLibrary reports whether pc is the package of the library file at
+// path: it is the first -l in Libs, and it lives in the package's library directory
+func (pc *PkgConfig) ProvidesLibrary(path string) bool {
+	base := filepath.Base(path)
+	libs, dirs := pc.linkNames()
+	if len(libs) == 0 || !strings.HasPrefix(base, "lib"+libs[0]+".") {
+		return false
+	}
+	if len(dirs) == 0 {
+		return true
+	}
+	for _, dir := range dirs {
+		if sameFile(filepath.Join(rootRealPath(dir), base), path) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesQuery reports whether a package belongs to a --lib query: the module
+// name contains it, or it is the package of a library of that name
+func (pc *PkgConfig) MatchesQuery(libName string) bool {
+	if strings.Contains(strings.ToLower(pc.Module), strings.ToLower(libName)) {
+		return true
+	}
+	libs, _ := pc.linkNames()
+	return len(libs) > 0 && libs[0] == libName
+}
+
+// FindPkgConfigPackages lists the .pc files matching a --lib query
+func FindPkgConfigPackages(name string) ([]*PkgConfig, error) {
+	libName := normalizeLibName(name)
+	var found []*PkgConfig
+	for _, pc := range FindPkgConfigs() {
+		if pc.MatchesQuery(libName) {
+			found = append(found, pc)
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("no pkg-config package matches '%s' in %s", name, strings.Join(PkgConfigDirs(), ":"))
+	}
+	return found, nil
+}
+
+// FormatPkgConfig renders one package with colors
+func FormatPkgConfig(pc *PkgConfig, treeFn, pathFn, warnFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	title := pc.Module
+	if pc.Version != "" {
+		title += " " + pc.Version
+	}
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Package  :"), pathFn(title))
+	if pc.ShadowedBy != "" {
+		fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Shadowed :"), warnFn("by "+pc.ShadowedBy))
+	}
+	if pc.Description != "" {
+		fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("About    :"), valueFn(pc.Description))
+	}
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("File     :"), valueFn(pc.Path))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Cflags   :"), valueFn(orNone(pc.Cflags)))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Libs     :"), valueFn(orNone(pc.Libs)))
+	requires := valueFn(orNone(pc.Requires))
+	if pc.RequiresPrivate != "" {
+		requires += " " + treeFn("(private: "+pc.RequiresPrivate+")")
+	}
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("╰─"), treeFn("Requires :"), requires)
+	return sb.String()
+}
+
+// FormatPkgConfigs renders the packages found for a --lib --pc query
+func FormatPkgConfigs(pcs []*PkgConfig, query string, labelFn, treeFn, pathFn, warnFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Found %d pkg-config package(s) for '%s':\n", len(pcs), query)
+	for _, pc := range pcs {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn(pc.Name+":"))
+		sb.WriteString(FormatPkgConfig(pc, treeFn, pathFn, warnFn, valueFn))
+	}
+	return sb.String()
+}
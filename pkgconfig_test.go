@@ -0,0 +1,105 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// pcDir holds .pc fixtures; its libraries are in testdata/pkgconfig/lib
+var pcDir = filepath.Join("testdata", "pkgconfig", "lib", "pkgconfig")
+
+func TestParsePkgConfig(t *testing.T) {
+	path := filepath.Join(pcDir, "foo.pc")
+	pc, err := ParsePkgConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := pcDir + "/../.."
+	want := PkgConfig{
+		Module:          "foo",
+		Path:            path,
+		Name:            "Foo",
+		Description:     "A library split over two lines",
+		Version:         "1.2.3",
+		Cflags:          "-I" + prefix + "/include/foo -DFOO_OK",
+		Libs:            "-L" + prefix + "/lib -lfoo -lm",
+		LibsPrivate:     "-lpthread",
+		Requires:        "bar >= 1.0",
+		RequiresPrivate: "zlib",
+	}
+	got := *pc
+	got.Vars = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+
+	// A '=' before any ':' makes a variable, even with a colon in the value
+	vars := map[string]string{
+		"pcfiledir":  pcDir,
+		"prefix":     prefix,
+		"libdir":     prefix + "/lib",
+		"includedir": prefix + "/include",
+		"url":        "http://example.com:8080/foo",
+	}
+	for name, value := range vars {
+		if pc.Vars[name] != value {
+			t.Errorf("variable %s = %q, want %q", name, pc.Vars[name], value)
+		}
+	}
+	if len(pc.Vars) != len(vars) {
+		t.Errorf("got variables %v, want %v", pc.Vars, vars)
+	}
+}
+
+func TestParsePkgConfigErrors(t *testing.T) {
+	_, err := ParsePkgConfig(filepath.Join(pcDir, "nameless.pc"))
+	if err == nil || !strings.Contains(err.Error(), "missing Name field") {
+		t.Errorf("got %v, want a missing Name error", err)
+	}
+	if _, err := ParsePkgConfig(filepath.Join(pcDir, "missing.pc")); err == nil {
+		t.Error("a missing file was parsed")
+	}
+}
+
+func TestPkgConfigLinkNames(t *testing.T) {
+	pc, err := ParsePkgConfig(filepath.Join(pcDir, "foo.pc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	libs, dirs := pc.linkNames()
+	if !slices.Equal(libs, []string{"foo", "m"}) {
+		t.Errorf("libs = %v, want [foo m]", libs)
+	}
+	libdir := pcDir + "/../../lib"
+	if !slices.Equal(dirs, []string{libdir, libdir}) {
+		t.Errorf("dirs = %v, want the -L directory and libdir", dirs)
+	}
+}
+
+func TestPkgConfigProvidesLibrary(t *testing.T) {
+	pc, err := ParsePkgConfig(filepath.Join(pcDir, "foo.pc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	libDir := filepath.Join("testdata", "pkgconfig", "lib")
+	tests := map[string]bool{
+		filepath.Join(libDir, "libfoo.so.1"):     true,
+		filepath.Join(libDir, "libfoobar.so.1"):  false, // a different library with the same prefix
+		filepath.Join("testdata", "libfoo.so.1"): false, // outside the package's libdir
+	}
+	for path, want := range tests {
+		if got := pc.ProvidesLibrary(path); got != want {
+			t.Errorf("ProvidesLibrary(%s) = %v, want %v", path, got, want)
+		}
+	}
+
+	for query, want := range map[string]bool{"foo": true, "FO": true, "m": false, "bar": false} {
+		if got := pc.MatchesQuery(query); got != want {
+			t.Errorf("MatchesQuery(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
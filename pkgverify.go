@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Results of checking a file against its package database
+const (
+	CheckOK         = "ok"
+	CheckModified   = "modified"
+	CheckMissing    = "missing"
+	CheckUnreadable = "unreadable"
+	CheckUnchecked  = "unchecked" // no recorded checksum, or a symlink
+)
+
+// FileCheck is the result of comparing a file with the checksum recorded
+// when its package was installed
+type FileCheck struct {
+	File     string // path as recorded in the database
+	Status   string
+	Expected PackageDigest
+	Actual   string // hex digest of the file now, with the same algorithm
+	Conffile bool
+	Err      error
+}
+
+// PackageVerification is the result of checking every file of a package
+type PackageVerification struct {
+	Package *InstalledPackage
+	Checks  []*FileCheck
+}
+
+// Failed reports whether a file that is not a conffile was modified or
+// removed; conffiles are meant to be edited
+func (v *PackageVerification) Failed() bool {
+	for _, c := range v.Checks {
+		if c.Status == CheckMissing || c.Status == CheckUnreadable || (c.Status == CheckModified && !c.Conffile) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPackageFile compares the file at hostPath with the checksum p recorded
+// for file
+func CheckPackageFile(p *InstalledPackage, file, hostPath string) *FileCheck {
+	c := &FileCheck{File: file, Conffile: p.Conffiles[file]}
+	d, ok := p.Digests()[file]
+	if !ok {
+		c.Status = CheckUnchecked
+		return c
+	}
+	c.Expected = d
+
+	info, err := os.Lstat(hostPath)
+	switch {
+	case os.IsNotExist(err):
+		c.Status = CheckMissing
+		return c
+	case err != nil:
+		c.Status, c.Err = CheckUnreadable, err
+		return c
+	case info.Mode()&os.ModeSymlink != 0:
+		// apk records a checksum of the link target's name, not of any content
+		c.Status = CheckUnchecked
+		return c
+	}
+
+	actual, err := fileDigest(hostPath, d.Algorithm)
+	if err != nil {
+		c.Status, c.Err = CheckUnreadable, err
+		return c
+	}
+	c.Actual = actual
+	c.Status = CheckOK
+	if !strings.EqualFold(actual, d.Sum) {
+		c.Status = CheckModified
+	}
+	return c
+}
+
+// fileDigest hashes a file with the named algorithm
+func fileDigest(path, algorithm string) (string, error) {
+	var h hash.Hash
+	switch algorithm {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return "", fmt.Errorf("unsupported checksum algorithm %s", algorithm)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyPackages checks every file with a recorded checksum in the named
+// packages under root, like debsums. A name may carry an architecture
+// (libssl3:amd64) to pick one of several installed copies.
+func VerifyPackages(root string, names []string) ([]*PackageVerification, error) {
+	installed := InstalledPackages(root)
+	if len(installed) == 0 {
+		return nil, fmt.Errorf("no dpkg, apk or pacman database found under %s", root)
+	}
+
+	var results []*PackageVerification
+	for _, name := range names {
+		pkgName, arch, _ := strings.Cut(name, ":")
+		found := false
+		for _, p := range installed {
+			if p.Name == pkgName && (arch == "" || p.Arch == arch) {
+				results = append(results, verifyPackage(root, p))
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("package '%s' is not installed", name)
+		}
+		if err := HashContext.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// verifyPackage hashes the files of one package in parallel
+func verifyPackage(root string, p *InstalledPackage) *PackageVerification {
+	var files []string
+	for file := range p.Digests() {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	checks := make([]*FileCheck, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				hostPath := filepath.Join(root, files[i])
+				if root == sysroot {
+					// Absolute symlinked directories point inside the root
+					hostPath = rootPath(files[i])
+				}
+				checks[i] = CheckPackageFile(p, files[i], hostPath)
+			}
+		}()
+	}
+	for i := range files {
+		if HashContext.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	v := &PackageVerification{Package: p}
+	for _, c := range checks {
+		if c != nil {
+			v.Checks = append(v.Checks, c)
+		}
+	}
+	return v
+}
+
+// formatCheck describes a check result in a few words
+func formatCheck(c *FileCheck, treeFn, pathFn, warnFn, valueFn func(a ...interface{}) string) string {
+	switch c.Status {
+	case CheckOK:
+		return pathFn("✓ matches the " + c.Expected.Algorithm + " recorded at install")
+	case CheckModified:
+		if c.Conffile {
+			return valueFn("modified since install") + " " + treeFn("(conffile; local changes are kept on upgrade)")
+		}
+		return warnFn("✗ modified since install")
+	case CheckMissing:
+		return warnFn("✗ missing")
+	case CheckUnreadable:
+		return warnFn("✗ unreadable: " + c.Err.Error())
+	}
+	return treeFn("no recorded checksum")
+}
+
+// FormatPackageVerifications renders pkg-verify results: problems for each
+// package, or every file when all is set
+func FormatPackageVerifications(results []*PackageVerification, all bool, labelFn, treeFn, pathFn, warnFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	for _, v := range results {
+		p := v.Package
+		counts := make(map[string]int)
+		var shown []*FileCheck
+		for _, c := range v.Checks {
+			counts[c.Status]++
+			if all || (c.Status != CheckOK && c.Status != CheckUnchecked) {
+				shown = append(shown, c)
+			}
+		}
+
+		checked := len(v.Checks) - counts[CheckUnchecked]
+		noun := "files"
+		if checked == 1 {
+			noun = "file"
+		}
+		summary := fmt.Sprintf("%d %s checked", checked, noun)
+		for _, status := range []string{CheckModified, CheckMissing, CheckUnreadable} {
+			if counts[status] > 0 {
+				summary += fmt.Sprintf(", %d %s", counts[status], status)
+			}
+		}
+		fmt.Fprintf(&sb, "\n%s %s %s\n", labelFn(p.Name+" "+p.Version+":"), valueFn(summary),
+			treeFn("("+strings.Join([]string{p.Arch, p.Manager}, ", ")+")"))
+		if len(shown) == 0 {
+			fmt.Fprintf(&sb, "  %s %s\n", treeFn("╰─"), pathFn("✓ all files match the checksums recorded at install"))
+			continue
+		}
+		for i, c := range shown {
+			branch, cont := "├─", "│ "
+			if i == len(shown)-1 {
+				branch, cont = "╰─", "  "
+			}
+			fmt.Fprintf(&sb, "  %s %s %s\n", treeFn(branch), pathFn(c.File), formatCheck(c, treeFn, pathFn, warnFn, valueFn))
+			if c.Status == CheckModified {
+				fmt.Fprintf(&sb, "  %s   %s %s\n", treeFn(cont), treeFn("expected "+c.Expected.Algorithm+":"), valueFn(c.Expected.Sum))
+				fmt.Fprintf(&sb, "  %s   %s %s\n", treeFn(cont), treeFn("actual   "+c.Expected.Algorithm+":"), warnFn(c.Actual))
+			}
+		}
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// ShowProgressFlag enables progress reporting on stderr while hashing
+var ShowProgressFlag bool
+
+// ProgressFileCount is the number of files expected to be hashed in this run
+var ProgressFileCount int
+
+// HashContext is used to cancel in-flight hash calculations
+var HashContext = context.Background()
+
+// progressMinSize is the smallest file that gets a live progress bar
+const progressMinSize = 8 * 1024 * 1024
+
+// progressInterval is how often the progress bar is redrawn
+const progressInterval = 100 * time.Millisecond
+
+// progressOutput is where progress is written (never stdout, so JSON stays clean)
+var progressOutput io.Writer = os.Stderr
+
+// progressEnabled reports whether progress should be drawn on stderr
+func progressEnabled() bool {
+	if !ShowProgressFlag {
+		return false
+	}
+	if f, ok := progressOutput.(*os.File); ok {
+		return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	}
+	return false
+}
+
+// ProgressReporter draws a single-line progress bar for one file
+type ProgressReporter struct {
+	mu       sync.Mutex
+	out      io.Writer
+	name     string
+	total    int64
+	done     int64
+	start    time.Time
+	lastDraw time.Time
+	drawBar  bool
+}
+
+// NewProgressReporter creates a reporter for a file of the given size
+func NewProgressReporter(out io.Writer, path string, total int64) *ProgressReporter {
+	return &ProgressReporter{
+		out:     out,
+		name:    filepath.Base(path),
+		total:   total,
+		start:   time.Now(),
+		drawBar: total >= progressMinSize,
+	}
+}
+
+// Write implements io.Writer so the reporter can sit in an io.MultiWriter
+func (p *ProgressReporter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done += int64(len(b))
+	if p.drawBar && time.Since(p.lastDraw) >= progressInterval {
+		p.lastDraw = time.Now()
+		p.draw()
+	}
+	return len(b), nil
+}
+
+// draw renders the progress line: bar, bytes processed, throughput and ETA
+func (p *ProgressReporter) draw() {
+	elapsed := time.Since(p.start).Seconds()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.done) / elapsed
+	}
+
+	percent := 0.0
+	if p.total > 0 {
+		percent = float64(p.done) / float64(p.total)
+	}
+
+	const width = 24
+	filled := int(percent * width)
+	if filled > width {
+		filled = width
+	}
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+
+	eta := "--:--"
+	if rate > 0 && p.total > p.done {
+		eta = formatDuration(time.Duration(float64(p.total-p.done) / rate * float64(time.Second)))
+	}
+
+	_, _ = fmt.Fprintf(p.out, "\r\033[K%s %s %3.0f%% %s / %s  %s/s  ETA %s",
+		p.name, bar, percent*100,
+		formatBytes(p.done), formatBytes(p.total),
+		formatBytes(int64(rate)), eta)
+}
+
+// Finish clears the progress bar and, when hashing many files, prints a per-file summary line
+func (p *ProgressReporter) Finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.drawBar {
+		_, _ = fmt.Fprint(p.out, "\r\033[K")
+	}
+	if ProgressFileCount <= 1 {
+		return
+	}
+
+	elapsed := time.Since(p.start)
+	if err != nil {
+		_, _ = fmt.Fprintf(p.out, "✗ %s: %v\n", p.name, err)
+		return
+	}
+	rate := float64(p.done)
+	if elapsed.Seconds() > 0 {
+		rate /= elapsed.Seconds()
+	}
+	_, _ = fmt.Fprintf(p.out, "✓ %s  %s in %s (%s/s)\n",
+		p.name, formatBytes(p.done), elapsed.Round(10*time.Millisecond), formatBytes(int64(rate)))
+}
+
+// contextReader aborts reads once its context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+// formatBytes formats a byte count using binary units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatDuration formats a duration as m:ss or h:mm:ss
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SymbolDefinition is a symbol defined in a library's dynamic symbol table
+type SymbolDefinition struct {
+	Query   string // the name or pattern that matched
+	Library string
+	Soname  string
+	Symbol  string
+	Version string // e.g. OPENSSL_3.0.0; empty for unversioned symbols
+	Default bool   // symbol@@VERSION, the version new links bind to
+	Binding string // GLOBAL, WEAK or UNIQUE
+	Type    string // FUNC, OBJECT, IFUNC, TLS, ...
+}
+
+// FindSymbolProviders scans every shared library in the FindLibrary search
+// paths, in parallel, for dynamic symbols defining one of symbols. Names with
+// wildcards are matched as globs.
+func FindSymbolProviders(symbols []string) ([]SymbolDefinition, error) {
+	for _, s := range symbols {
+		if _, err := filepath.Match(s, ""); err != nil {
+			return nil, fmt.Errorf("invalid symbol pattern '%s': %w", s, err)
+		}
+	}
+	libraries := sharedLibraries()
+
+	results := make([][]SymbolDefinition, len(libraries))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = librarySymbols(libraries[i], symbols)
+			}
+		}()
+	}
+	for i := range libraries {
+		if HashContext.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err := HashContext.Err(); err != nil {
+		return nil, err
+	}
+
+	var defs []SymbolDefinition
+	for _, r := range results {
+		defs = append(defs, r...)
+	}
+	return defs, nil
+}
+
+// sharedLibraries lists each shared library in the search paths and the
+// loader cache once, by its resolved path
+func sharedLibraries() []string {
+	var libraries []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		real, err := evalSymlinks(path)
+		if err != nil || seen[real] {
+			return
+		}
+		seen[real] = true
+		libraries = append(libraries, real)
+	}
+
+	for _, sp := range librarySearchDirs(hostEnvList("LD_LIBRARY_PATH")) {
+		entries, err := os.ReadDir(sp.dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if !e.IsDir() && (strings.HasSuffix(name, ".so") || strings.Contains(name, ".so.")) {
+				add(filepath.Join(sp.dir, name))
+			}
+		}
+	}
+	for _, e := range loaderCache() {
+		add(e.Path)
+	}
+	sort.Strings(libraries)
+	return libraries
+}
+
+// librarySymbols returns the definitions of the wanted symbols in one library
+func librarySymbols(path string, symbols []string) []SymbolDefinition {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	dynsyms, err := f.DynamicSymbols()
+	if err != nil {
+		return nil
+	}
+	soname := ""
+	if s, _ := f.DynString(elf.DT_SONAME); len(s) > 0 {
+		soname = s[0]
+	}
+
+	var defs []SymbolDefinition
+	for _, sym := range dynsyms {
+		bind := elf.ST_BIND(sym.Info)
+		if sym.Section == elf.SHN_UNDEF || bind == elf.STB_LOCAL {
+			continue
+		}
+		for _, query := range symbols {
+			if ok, _ := filepath.Match(query, sym.Name); !ok {
+				continue
+			}
+			defs = append(defs, SymbolDefinition{
+				Query:   query,
+				Library: path,
+				Soname:  soname,
+				Symbol:  sym.Name,
+				Version: sym.Version,
+				Default: !sym.HasVersion || !sym.VersionIndex.IsHidden(),
+				Binding: symbolBinding(bind),
+				Type:    symbolType(elf.ST_TYPE(sym.Info)),
+			})
+			break
+		}
+	}
+	return defs
+}
+
+// symbolBinding names a symbol binding without its STB_ prefix
+func symbolBinding(bind elf.SymBind) string {
+	if bind == 10 {
+		return "UNIQUE" // STB_GNU_UNIQUE
+	}
+	return strings.TrimPrefix(bind.String(), "STB_")
+}
+
+// symbolType names a symbol type without its STT_ prefix
+func symbolType(typ elf.SymType) string {
+	if typ == 10 {
+		return "IFUNC" // STT_GNU_IFUNC
+	}
+	return strings.TrimPrefix(typ.String(), "STT_")
+}
+
+// FormatSymbolProviders renders the libraries defining each symbol
+func FormatSymbolProviders(defs []SymbolDefinition, symbols []string, labelFn, treeFn, pathFn, warnFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	for _, query := range symbols {
+		var matches []SymbolDefinition
+		libraries := make(map[string]bool)
+		for _, d := range defs {
+			if d.Query == query {
+				matches = append(matches, d)
+				libraries[d.Library] = true
+			}
+		}
+
+		noun := "libraries"
+		if len(libraries) == 1 {
+			noun = "library"
+		}
+		fmt.Fprintf(&sb, "\n%s %s\n", labelFn(query+":"), treeFn(fmt.Sprintf("defined in %d %s", len(libraries), noun)))
+		if len(matches) == 0 {
+			fmt.Fprintf(&sb, "  %s %s\n", treeFn("╰─"), warnFn("✗ not defined by any library in the search path"))
+			continue
+		}
+		for i, d := range matches {
+			branch := "├─"
+			if i == len(matches)-1 {
+				branch = "╰─"
+			}
+			name := d.Symbol
+			switch {
+			case d.Version != "" && d.Default:
+				name += "@@" + d.Version
+			case d.Version != "":
+				name += "@" + d.Version
+			}
+			details := d.Binding + " " + d.Type
+			if d.Soname != "" && d.Soname != filepath.Base(d.Library) {
+				details += ", SONAME " + d.Soname
+			}
+			fmt.Fprintf(&sb, "  %s %s %s %s\n", treeFn(branch), pathFn(d.Library), valueFn(name), treeFn("("+details+")"))
+		}
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReverseDep is a file that loads the searched library
+type ReverseDep struct {
+	Path   string
+	Direct bool   // the library is in its own DT_NEEDED
+	Via    string // for indirect users, the needed library that leads to it
+}
+
+// ReverseDeps lists the users of a library
+type ReverseDeps struct {
+	Library string // the SONAME searched for
+	Users   []ReverseDep
+	Scanned int // ELF files read
+}
+
+// elfNode is the dynamic linking information of one scanned ELF file
+type elfNode struct {
+	path     string
+	real     string   // path with symlinks resolved
+	needed   []string // DT_NEEDED entries
+	resolved []string // the file the loader would open for each needed entry, "" if none
+}
+
+// FindReverseDeps finds the ELF files that need library, directly or through
+// other libraries. With no dirs, PATH and the library search directories are
+// scanned; otherwise the given trees are walked. Library directories are
+// always read so that indirect users are found through libraries elsewhere.
+// Each DT_NEEDED entry is resolved the way the loader would for that file,
+// so a program that ships its own copy of the library is not a user of the
+// system one. A bare SONAME stands for the copies the loader finds without
+// a RUNPATH.
+func FindReverseDeps(library string, dirs []string) (*ReverseDeps, error) {
+	cache := loaderCache()
+	confDirs := uncachedLoaderDirs(cache)
+
+	target := library
+	targets := make(map[string]bool)
+	if f, err := elf.Open(rootRealPath(library)); err == nil {
+		target = filepath.Base(library)
+		if soname, _ := f.DynString(elf.DT_SONAME); len(soname) > 0 {
+			target = soname[0]
+		}
+		_ = f.Close()
+		if real, err := evalSymlinks(rootRealPath(library)); err == nil {
+			targets[real] = true
+		}
+	} else {
+		for _, path := range systemLibraryCopies(target, confDirs, cache) {
+			targets[path] = true
+		}
+	}
+
+	var reported, flat []string
+	var libDirs []string
+	for _, sp := range librarySearchDirs(hostEnvList("LD_LIBRARY_PATH")) {
+		libDirs = append(libDirs, sp.dir)
+	}
+	if len(dirs) == 0 {
+		flat = append(filepath.SplitList(systemPath()), libDirs...)
+	} else {
+		for _, dir := range dirs {
+			info, err := rootStat(dir)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				return nil, fmt.Errorf("%s is not a directory", dir)
+			}
+		}
+		reported = dirs
+	}
+
+	// Collect candidate files once each, by resolved path
+	var files []string
+	report := make(map[string]bool)
+	seen := make(map[string]bool)
+	add := func(path string, reportIt bool) {
+		real, err := evalSymlinks(path)
+		if err != nil {
+			return
+		}
+		if !seen[real] {
+			seen[real] = true
+			files = append(files, path)
+		}
+		report[real] = report[real] || reportIt
+	}
+	for _, dir := range flat {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				add(filepath.Join(dir, e.Name()), len(dirs) == 0)
+			}
+		}
+	}
+	for _, root := range reported {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // unreadable directories are skipped
+			}
+			if d.Type().IsRegular() {
+				add(path, true)
+			}
+			return HashContext.Err()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(dirs) > 0 {
+		for _, dir := range libDirs {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				if name := e.Name(); !e.IsDir() && (strings.HasSuffix(name, ".so") || strings.Contains(name, ".so.")) {
+					add(filepath.Join(dir, name), false)
+				}
+			}
+		}
+	}
+
+	nodes := readELFNodes(files, confDirs, cache)
+	if err := HashContext.Err(); err != nil {
+		return nil, err
+	}
+
+	// Spread outwards from the library until no new user turns up; users are
+	// keyed by resolved path
+	result := &ReverseDeps{Library: target, Scanned: len(nodes)}
+	users := make(map[string]bool)
+	for path := range targets {
+		users[path] = true
+	}
+	marked := make(map[*elfNode]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, n := range nodes {
+			if marked[n] || targets[n.real] {
+				continue
+			}
+			direct, via := false, ""
+			for i, lib := range n.resolved {
+				if targets[lib] {
+					direct = true
+					break
+				}
+				if via == "" && users[lib] {
+					via = n.needed[i]
+				}
+			}
+			if !direct && via == "" {
+				continue
+			}
+			if direct {
+				via = ""
+			}
+			marked[n] = true
+			changed = true
+			users[n.real] = true
+			if report[n.real] {
+				result.Users = append(result.Users, ReverseDep{Path: n.path, Direct: direct, Via: via})
+			}
+		}
+	}
+	sort.Slice(result.Users, func(i, j int) bool { return result.Users[i].Path < result.Users[j].Path })
+	return result, nil
+}
+
+// systemLibraryCopies lists the resolved files the loader finds for a SONAME
+// without any RUNPATH, one per architecture in ld.so.cache
+func systemLibraryCopies(soname string, confDirs []string, cache []LdCacheEntry) []string {
+	var paths []string
+	add := func(path string) {
+		if real, err := evalSymlinks(path); err == nil && !containsString(paths, real) {
+			paths = append(paths, real)
+		}
+	}
+	for _, e := range cache {
+		if e.Soname == soname {
+			add(e.Path)
+		}
+	}
+	for _, dir := range append(confDirs, loaderDefaultDirs()...) {
+		if path := filepath.Join(dir, soname); fileExists(path) {
+			add(path)
+		}
+	}
+	return paths
+}
+
+// readELFNodes reads and resolves the DT_NEEDED entries of each ELF file, in parallel
+func readELFNodes(files, confDirs []string, cache []LdCacheEntry) []*elfNode {
+	nodes := make([]*elfNode, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				nodes[i] = readELFNode(files[i], confDirs, cache)
+			}
+		}()
+	}
+	for i := range files {
+		if HashContext.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var found []*elfNode
+	for _, n := range nodes {
+		if n != nil {
+			found = append(found, n)
+		}
+	}
+	return found
+}
+
+// readELFNode returns the linking information of a dynamically linked ELF file,
+// or nil. Needed libraries are looked up as rootLinkedLibraries does, through
+// the file's RUNPATH and then the loader's cache and directories for its
+// class and machine.
+func readELFNode(path string, confDirs []string, cache []LdCacheEntry) *elfNode {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	// Most files in a tree are not ELF; check the magic before parsing
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil || !bytes.Equal(magic, []byte(elf.ELFMAG)) {
+		return nil
+	}
+	ef, err := elf.NewFile(f)
+	if err != nil {
+		return nil
+	}
+	needed, err := ef.ImportedLibraries()
+	if err != nil {
+		return nil
+	}
+	real, err := evalSymlinks(path)
+	if err != nil {
+		return nil
+	}
+	n := &elfNode{path: path, real: real, needed: needed, resolved: make([]string, len(needed))}
+	arch, is64 := elfArch(ef), ef.Class == elf.ELFCLASS64
+	searchDirs := append(elfRunPath(ef, path), confDirs...)
+	for i, soname := range needed {
+		var found string
+		if strings.Contains(soname, "/") {
+			found = rootPath(soname)
+		} else {
+			found = loaderPick(soname, arch, is64, searchDirs, cache)
+		}
+		if found != "" {
+			n.resolved[i], _ = evalSymlinks(found)
+		}
+	}
+	return n
+}
+
+// FormatReverseDeps renders the users of a library grouped by directory
+func FormatReverseDeps(r *ReverseDeps, labelFn, treeFn, pathFn, warnFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	byDir := make(map[string][]ReverseDep)
+	direct := 0
+	for _, u := range r.Users {
+		dir := filepath.Dir(u.Path)
+		byDir[dir] = append(byDir[dir], u)
+		if u.Direct {
+			direct++
+		}
+	}
+
+	noun := "directories"
+	if len(byDir) == 1 {
+		noun = "directory"
+	}
+	fmt.Fprintf(&sb, "\n%s %s %s\n", labelFn("Users of "+r.Library+":"),
+		valueFn(fmt.Sprintf("%d direct, %d indirect in %d %s", direct, len(r.Users)-direct, len(byDir), noun)),
+		treeFn(fmt.Sprintf("(%d ELF files scanned)", r.Scanned)))
+	if len(r.Users) == 0 {
+		fmt.Fprintf(&sb, "  %s %s\n", treeFn("╰─"), warnFn("✗ nothing scanned links against "+r.Library))
+	}
+
+	var dirs []string
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		users := byDir[dir]
+		n := 0
+		for _, u := range users {
+			if u.Direct {
+				n++
+			}
+		}
+		fmt.Fprintf(&sb, "\n%s %s\n", labelFn(dir), treeFn(fmt.Sprintf("(%d direct, %d indirect)", n, len(users)-n)))
+		for i, u := range users {
+			branch := "├─"
+			if i == len(users)-1 {
+				branch = "╰─"
+			}
+			if u.Direct {
+				fmt.Fprintf(&sb, "  %s %s\n", treeFn(branch), pathFn(filepath.Base(u.Path)))
+			} else {
+				fmt.Fprintf(&sb, "  %s %s %s\n", treeFn(branch), valueFn(filepath.Base(u.Path)), treeFn("(via "+u.Via+")"))
+			}
+		}
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
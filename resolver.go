@@ -1,87 +1,298 @@
 package main
 
 import (
+	"debug/elf"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 )
 
+// PathMatch is one file named like the command in a PATH directory
+type PathMatch struct {
+	Path       string
+	Winner     bool   // the file the shell would run
+	Executable bool   // has an execute bit (after following symlinks)
+	Broken     bool   // a symlink whose target does not exist
+	Target     string // symlink target, if Path is a symlink
+}
+
+// PathLookup records every PATH match for a command and any unsafe PATH entries
+type PathLookup struct {
+	Name     string
+	Matches  []PathMatch
+	Warnings []string
+	Shim     *ShimInfo // set when the winner is a version-manager shim
+}
+
+// Winner returns the match the shell would run, or nil
+func (l *PathLookup) Winner() *PathMatch {
+	for i := range l.Matches {
+		if l.Matches[i].Winner {
+			return &l.Matches[i]
+		}
+	}
+	return nil
+}
+
+// nextOutside returns the first executable match that is not under dir, as
+// used by a shim set to the "system" version
+func (l *PathLookup) nextOutside(dir string) string {
+	for _, m := range l.Matches {
+		abs, err := filepath.Abs(m.Path)
+		if err == nil && m.Executable && !strings.HasPrefix(abs, dir+"/") {
+			return abs
+		}
+	}
+	return ""
+}
+
+// pathLookups keeps the lookup behind each resolved command for FormatFileInfo
+var pathLookups = make(map[string]*PathLookup)
+
 // ResolveCommand tries to find a command in PATH
 func ResolveCommand(name string) (string, error) {
 	// First check if it's already a valid path
 	if _, err := os.Stat(name); err == nil {
 		return filepath.Abs(name)
 	}
+	if strings.Contains(name, "/") {
+		return "", fmt.Errorf("'%s' does not exist", name)
+	}
 
-	// Try to find it in PATH using 'which'
-	cmd := exec.Command("which", name)
-	output, err := cmd.Output()
-	if err != nil {
+	lookup := LookPath(name, systemPath())
+	winner := lookup.Winner()
+	if winner == nil {
+		if len(lookup.Matches) > 0 {
+			return "", fmt.Errorf("command '%s' found in PATH but not executable: %s", name, lookup.Matches[0].Path)
+		}
 		return "", fmt.Errorf("command '%s' not found in PATH", name)
 	}
 
-	path := strings.TrimSpace(string(output))
+	path, err := filepath.Abs(winner.Path)
+	if err != nil {
+		return "", err
+	}
+
+	// Follow version-manager shims to the interpreter they would run; their
+	// configuration lives in the host's home directory, not in a root
+	if sysroot == "" {
+		lookup.Shim = ResolveShim(path, name, lookup.nextOutside)
+	}
+	if lookup.Shim != nil && lookup.Shim.Target != "" {
+		if path, err = filepath.Abs(lookup.Shim.Target); err != nil {
+			return "", err
+		}
+	}
+	pathLookups[path] = lookup
 	return path, nil
 }
 
-// FindLibrary searches for library files (.so, .a, .dylib) in common library paths
-func FindLibrary(name string) ([]string, error) {
-	// Common library search paths
-	searchPaths := []string{
-		"/usr/lib",
-		"/usr/local/lib",
-		"/opt/homebrew/lib",
-		"/lib",
-		"/usr/lib64",
-		"/usr/local/lib64",
+// LookPath walks every directory in pathList, in order, and lists each file
+// called name. The first executable match wins, as in the shell; empty or
+// relative entries and world-writable directories are reported as warnings.
+func LookPath(name, pathList string) *PathLookup {
+	lookup := &PathLookup{Name: name}
+	seen := make(map[string]bool)
+
+	for i, dir := range filepath.SplitList(pathList) {
+		switch {
+		case dir == "":
+			lookup.Warnings = append(lookup.Warnings, fmt.Sprintf("PATH entry %d is empty and searches the current directory", i+1))
+			dir = "."
+		case !filepath.IsAbs(dir):
+			lookup.Warnings = append(lookup.Warnings, fmt.Sprintf("PATH entry %d (%s) is relative to the current directory", i+1, dir))
+		}
+
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		if info.Mode().Perm()&0o002 != 0 {
+			note := ""
+			if info.Mode()&os.ModeSticky != 0 {
+				note = " (sticky)"
+			}
+			lookup.Warnings = append(lookup.Warnings, fmt.Sprintf("PATH entry %d (%s) is world-writable%s", i+1, dir, note))
+		}
+
+		// A directory listed twice can only ever match once
+		abs, err := filepath.Abs(dir)
+		if err != nil || seen[abs] {
+			continue
+		}
+		seen[abs] = true
+
+		if m, ok := pathMatch(filepath.Join(dir, name)); ok {
+			if m.Executable && lookup.Winner() == nil {
+				m.Winner = true
+			}
+			lookup.Matches = append(lookup.Matches, m)
+		}
 	}
+	return lookup
+}
 
-	// Add LD_LIBRARY_PATH if set
-	if ldPath := os.Getenv("LD_LIBRARY_PATH"); ldPath != "" {
-		paths := strings.Split(ldPath, ":")
-		searchPaths = append(searchPaths, paths...)
+// pathMatch inspects a candidate file; directories are not commands
+func pathMatch(path string) (PathMatch, bool) {
+	linfo, err := os.Lstat(path)
+	if err != nil {
+		return PathMatch{}, false
+	}
+	m := PathMatch{Path: path}
+	if linfo.Mode()&os.ModeSymlink != 0 {
+		m.Target, _ = os.Readlink(path)
 	}
 
-	// Add DYLD_LIBRARY_PATH for macOS
-	if dyldPath := os.Getenv("DYLD_LIBRARY_PATH"); dyldPath != "" {
-		paths := strings.Split(dyldPath, ":")
-		searchPaths = append(searchPaths, paths...)
+	info, err := rootStat(path)
+	if err != nil {
+		m.Broken = m.Target != ""
+		return m, m.Broken
+	}
+	if info.IsDir() {
+		return PathMatch{}, false
 	}
+	m.Executable = info.Mode().Perm()&0o111 != 0
+	return m, true
+}
 
-	var found []string
-	extensions := []string{".dylib", ".so", ".a"}
+// FormatPathLookup renders every PATH match, the winner first in PATH order
+func FormatPathLookup(l *PathLookup, treeFn, pathFn, warnFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	for i, m := range l.Matches {
+		branch := "├─"
+		if i == len(l.Matches)-1 && len(l.Warnings) == 0 {
+			branch = "╰─"
+		}
 
-	// Normalize library name (remove lib prefix and extension if present)
-	libName := strings.TrimPrefix(name, "lib")
-	for _, ext := range extensions {
-		libName = strings.TrimSuffix(libName, ext)
+		var marker, path, note string
+		switch {
+		case m.Winner && l.Shim != nil:
+			marker, path, note = pathFn("★"), pathFn(m.Path), "runs, "+l.Shim.Manager+" shim"
+		case m.Winner:
+			marker, path, note = pathFn("★"), pathFn(m.Path), "runs"
+		case m.Broken:
+			marker, path, note = warnFn("✗"), warnFn(m.Path), "broken symlink"
+		case !m.Executable:
+			marker, path, note = warnFn("✗"), warnFn(m.Path), "not executable"
+		default:
+			marker, path, note = " ", valueFn(m.Path), "shadowed"
+		}
+		if m.Target != "" {
+			note += ", → " + m.Target
+		}
+		fmt.Fprintf(&sb, "  %s %s %s %s\n", treeFn(branch), marker, path, treeFn("("+note+")"))
+	}
+	for i, w := range l.Warnings {
+		branch := "├─"
+		if i == len(l.Warnings)-1 {
+			branch = "╰─"
+		}
+		fmt.Fprintf(&sb, "  %s %s %s\n", treeFn(branch), warnFn("!"), warnFn(w))
 	}
+	return sb.String()
+}
 
-	// Search for the library
-	for _, searchPath := range searchPaths {
-		if _, err := os.Stat(searchPath); os.IsNotExist(err) {
-			continue
+// LibraryMatch annotates a library found by FindLibrary
+type LibraryMatch struct {
+	Path     string
+	Source   string // where the directory came from, e.g. "ld.so.conf" or "multiarch"
+	InCache  bool   // listed in /etc/ld.so.cache
+	Soname   string // DT_SONAME; empty for static archives and non-ELF files
+	Arch     string
+	Picked   string       // the file the dynamic loader would load for Soname
+	IsPicked bool         // this is that file
+	Packages []*PkgConfig // pkg-config packages that link against it
+
+	score int // match quality, lower is better
+	order int // position in search order
+}
+
+// libraryMatches keeps the annotations behind each FindLibrary result for FormatFileInfo
+var libraryMatches = make(map[string]*LibraryMatch)
+
+// librarySearchDir is a directory to search and the reason it is searched
+type librarySearchDir struct {
+	dir    string
+	source string
+}
+
+// librarySearchDirs lists the directories FindLibrary searches, in order
+func librarySearchDirs(ldLibraryPath []string) []librarySearchDir {
+	var searchPaths []librarySearchDir
+	add := func(source string, dirs ...string) {
+		for _, dir := range dirs {
+			if dir != "" {
+				searchPaths = append(searchPaths, librarySearchDir{dir, source})
+			}
 		}
+	}
+	add("LD_LIBRARY_PATH", ldLibraryPath...)
+	add("ld.so.conf", ReadLdSoConf(rootRealPath(ldSoConf))...)
+	add("multiarch", multiarchDirs()...)
+	add("default",
+		rootRealPath("/usr/lib"),
+		rootRealPath("/usr/local/lib"),
+		rootRealPath("/opt/homebrew/lib"),
+		rootRealPath("/lib"),
+		rootRealPath("/usr/lib64"),
+		rootRealPath("/usr/local/lib64"),
+	)
 
-		// Try different naming patterns
-		patterns := []string{
-			fmt.Sprintf("lib%s.dylib", libName),
-			fmt.Sprintf("lib%s.so", libName),
-			fmt.Sprintf("lib%s.so.*", libName),
-			fmt.Sprintf("lib%s.a", libName),
-			fmt.Sprintf("%s.dylib", libName),
-			fmt.Sprintf("%s.so", libName),
-			fmt.Sprintf("%s.a", libName),
+	// Add DYLD_LIBRARY_PATH for macOS
+	add("DYLD_LIBRARY_PATH", hostEnvList("DYLD_LIBRARY_PATH")...)
+	return searchPaths
+}
+
+// FindLibrary searches for library files (.so, .a, .dylib) in the directories the
+// dynamic loader uses: LD_LIBRARY_PATH, ld.so.conf, ld.so.cache and multiarch
+// directories, plus common install locations. mode selects how name is matched
+// (see libraryMatcher); results are ordered by library, newest version first.
+func FindLibrary(name, mode string) ([]string, error) {
+	ldLibraryPath := hostEnvList("LD_LIBRARY_PATH")
+	searchPaths := librarySearchDirs(ldLibraryPath)
+
+	match, err := libraryMatcher(name, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	// The same directory is often reachable twice, e.g. /lib -> usr/lib
+	var found []*LibraryMatch
+	seen := make(map[string]bool)
+	addFound := func(path, source string, score int) {
+		key := path
+		if dir, err := evalSymlinks(filepath.Dir(path)); err == nil {
+			key = filepath.Join(dir, filepath.Base(path))
+		}
+		if !seen[key] {
+			seen[key] = true
+			found = append(found, &LibraryMatch{Path: path, Source: source, score: score, order: len(found)})
 		}
+	}
 
-		for _, pattern := range patterns {
-			matches, err := filepath.Glob(filepath.Join(searchPath, pattern))
-			if err != nil {
+	// Search for the library
+	for _, sp := range searchPaths {
+		entries, err := os.ReadDir(sp.dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !isLibraryFile(e.Name()) {
 				continue
 			}
-			found = append(found, matches...)
+			if score := match(e.Name()); score >= 0 {
+				addFound(filepath.Join(sp.dir, e.Name()), sp.source, score)
+			}
+		}
+	}
+
+	// The cache may point into directories not listed anywhere else
+	cache := loaderCache()
+	ldLibraryPath = append(ldLibraryPath, uncachedLoaderDirs(cache)...)
+	for _, e := range cache {
+		if score := match(filepath.Base(e.Path)); score >= 0 && fileExists(e.Path) {
+			addFound(e.Path, "ld.so.cache", score)
 		}
 	}
 
@@ -89,15 +300,83 @@ func FindLibrary(name string) ([]string, error) {
 		return nil, fmt.Errorf("library '%s' not found in standard paths", name)
 	}
 
-	// Remove duplicates
-	seen := make(map[string]bool)
-	var unique []string
-	for _, path := range found {
-		if !seen[path] {
-			seen[path] = true
-			unique = append(unique, path)
+	sortLibraries(found)
+	pcs := FindPkgConfigs()
+	var paths []string
+	cached := cachedFiles(cache)
+	for _, m := range found {
+		annotateLibrary(m, ldLibraryPath, cache, cached)
+		for _, pc := range pcs {
+			if pc.ProvidesLibrary(m.Path) {
+				m.Packages = append(m.Packages, pc)
+			}
+		}
+		if abs, err := filepath.Abs(m.Path); err == nil {
+			libraryMatches[abs] = m
 		}
+		paths = append(paths, m.Path)
+	}
+	return paths, nil
+}
+
+// normalizeLibName removes the lib prefix and extension from a library name;
+// a name that is only "lib" is kept as is
+func normalizeLibName(name string) string {
+	libName := name
+	for _, ext := range []string{".dylib", ".so", ".a"} {
+		libName = strings.TrimSuffix(libName, ext)
 	}
+	if len(libName) > len("lib") {
+		libName = strings.TrimPrefix(libName, "lib")
+	}
+	return libName
+}
+
+// annotateLibrary fills in the SONAME, architecture and the loader's choice;
+// cached indexes the cache entries (see cachedFiles)
+func annotateLibrary(m *LibraryMatch, ldLibraryPath []string, cache []LdCacheEntry, cached map[string]bool) {
+	m.InCache = inCachedFiles(cached, m.Path)
+
+	f, err := elf.Open(m.Path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	m.Arch = elfArch(f)
+	if soname, _ := f.DynString(elf.DT_SONAME); len(soname) > 0 {
+		m.Soname = soname[0]
+	}
+	if m.Soname == "" || f.Type != elf.ET_DYN {
+		return
+	}
+	m.Picked = loaderPick(m.Soname, m.Arch, f.Class == elf.ELFCLASS64, ldLibraryPath, cache)
+	m.IsPicked = m.Picked != "" && filepath.Base(m.Path) == m.Soname && sameFile(m.Picked, m.Path)
+}
 
-	return unique, nil
+// FormatLibraryMatch renders the SONAME, architecture and loader choice of a library
+func FormatLibraryMatch(m *LibraryMatch, treeFn, pathFn, warnFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	source := m.Source
+	if m.InCache && source != "ld.so.cache" {
+		source += ", in ld.so.cache"
+	}
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("SONAME :"), valueFn(orNone(m.Soname)))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Arch   :"), valueFn(orNone(m.Arch)))
+
+	loader := treeFn("not loaded by SONAME")
+	switch {
+	case strings.HasSuffix(m.Path, ".a"):
+		loader = treeFn("static archive, linked at build time")
+	case m.IsPicked:
+		loader = pathFn("✓ picked by the dynamic loader for " + m.Soname)
+	case m.Picked != "" && filepath.Base(m.Path) != m.Soname:
+		loader = valueFn("link-time name; the loader opens " + m.Picked)
+	case m.Picked != "":
+		loader = warnFn("✗ shadowed; the loader picks " + m.Picked)
+	case m.Soname != "":
+		loader = warnFn("✗ the loader finds no " + m.Soname)
+	}
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Found  :"), valueFn(source))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("╰─"), treeFn("Loader :"), loader)
+	return sb.String()
 }
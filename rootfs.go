@@ -0,0 +1,289 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sysroot is the tree set by --root that lookups resolve inside, such as an
+// extracted container image or a cross-compilation sysroot; empty for the host
+var sysroot string
+
+// rootSearchPath is searched for commands inside a root, in place of the
+// host's PATH
+const rootSearchPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// maxSymlinks bounds symlink resolution, as ELOOP does in the kernel
+const maxSymlinks = 255
+
+// SetSysroot makes command, library, package and user lookups resolve inside dir
+func SetSysroot(dir string) error {
+	if dir == "" {
+		sysroot = ""
+		return nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return fmt.Errorf("root %s: %w", dir, err)
+	}
+	if info, err := os.Stat(real); err != nil || !info.IsDir() {
+		return fmt.Errorf("root %s is not a directory", dir)
+	}
+	if real == "/" {
+		real = ""
+	}
+	sysroot = real
+	return nil
+}
+
+// systemRoot is the directory package databases are read from
+func systemRoot() string {
+	if sysroot == "" {
+		return "/"
+	}
+	return sysroot
+}
+
+// rootPath maps an absolute path inside the root to the host. Symlinks in its
+// directories are resolved inside the root, absolute ones relative to it; the
+// last component is not followed, as with lstat. Relative paths, and any path
+// without --root, are returned unchanged.
+func rootPath(p string) string {
+	if sysroot == "" || !filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(sysroot, resolveInRoot(p, false))
+}
+
+// rootRealPath maps a path inside the root to the host, following every
+// symlink inside the root including the last one. Use it for directories to
+// list and files to read: the host would follow an absolute symlink out of
+// the root.
+func rootRealPath(p string) string {
+	if sysroot == "" || !filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(sysroot, resolveInRoot(p, true))
+}
+
+// resolveInRoot resolves the symlinks in p, a path inside sysroot, without
+// leaving it: absolute targets restart at the root and ".." stops there.
+// Missing components are kept as they are.
+func resolveInRoot(p string, followLast bool) string {
+	resolved := "/"
+	rest := strings.Split(filepath.ToSlash(p), "/")
+	links := 0
+	for len(rest) > 0 {
+		name := rest[0]
+		rest = rest[1:]
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, name)
+		if len(rest) == 0 && !followLast {
+			resolved = next
+			break
+		}
+		host := filepath.Join(sysroot, next)
+		info, err := os.Lstat(host)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 || links >= maxSymlinks {
+			resolved = next
+			continue
+		}
+		target, err := os.Readlink(host)
+		if err != nil {
+			resolved = next
+			continue
+		}
+		links++
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = append(strings.Split(filepath.ToSlash(target), "/"), rest...)
+	}
+	return resolved
+}
+
+// evalSymlinks is filepath.EvalSymlinks, except that host paths inside the
+// root have their symlinks resolved inside it
+func evalSymlinks(p string) (string, error) {
+	if sysroot == "" {
+		return filepath.EvalSymlinks(p)
+	}
+	rel, ok := pathInRoot(sysroot, p)
+	if !ok {
+		return filepath.EvalSymlinks(p)
+	}
+	real := filepath.Join(sysroot, resolveInRoot(rel, true))
+	if _, err := os.Lstat(real); err != nil {
+		return "", err
+	}
+	return real, nil
+}
+
+// rootStat is os.Stat, following symlinks inside the root
+func rootStat(p string) (os.FileInfo, error) {
+	real, err := evalSymlinks(p)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(real)
+}
+
+// hostEnvList splits a path-list environment variable; the host's settings
+// do not apply inside a root
+func hostEnvList(name string) []string {
+	if sysroot != "" {
+		return nil
+	}
+	return filepath.SplitList(os.Getenv(name))
+}
+
+// systemPath is the command search path: $PATH on the host, or the standard
+// directories inside a root
+func systemPath() string {
+	if sysroot == "" {
+		return os.Getenv("PATH")
+	}
+	var dirs []string
+	for _, dir := range filepath.SplitList(rootSearchPath) {
+		dirs = append(dirs, rootRealPath(dir))
+	}
+	return strings.Join(dirs, string(filepath.ListSeparator))
+}
+
+// rootLinkTarget returns the host path a symlink at link points to, reading
+// absolute targets as paths inside the root
+func rootLinkTarget(link, target string) string {
+	if !filepath.IsAbs(target) {
+		rel, ok := pathInRoot(sysroot, filepath.Dir(link))
+		if !ok {
+			return filepath.Join(filepath.Dir(link), target)
+		}
+		target = filepath.Join(rel, target)
+	}
+	return rootPath(target)
+}
+
+// rootLinkedLibraries lists the libraries an ELF file loads inside the root,
+// as ld.so would find them: DT_RUNPATH (or DT_RPATH), then ld.so.cache, then
+// the default directories, for the file and each library in turn. Running
+// ldd would ask the host's loader instead.
+func rootLinkedLibraries(path string) []string {
+	cache := loaderCache()
+	confDirs := uncachedLoaderDirs(cache)
+	var libs []string
+	seen := make(map[string]bool)
+	queue := []string{path}
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:]
+		f, err := elf.Open(obj)
+		if err != nil {
+			continue
+		}
+		needed, _ := f.ImportedLibraries()
+		arch, is64 := elfArch(f), f.Class == elf.ELFCLASS64
+		searchDirs := append(elfRunPath(f, obj), confDirs...)
+		_ = f.Close()
+
+		for _, soname := range needed {
+			if seen[soname] {
+				continue
+			}
+			seen[soname] = true
+			var found string
+			if strings.Contains(soname, "/") {
+				if found = rootPath(soname); !fileExists(found) {
+					found = ""
+				}
+			} else {
+				found = loaderPick(soname, arch, is64, searchDirs, cache)
+			}
+			if found == "" {
+				libs = append(libs, soname+" (not found)")
+				continue
+			}
+			libs = append(libs, found)
+			queue = append(queue, found)
+		}
+	}
+	return libs
+}
+
+// uncachedLoaderDirs stands in for a missing ld.so.cache inside a root.
+// Sysroots often have none, so the ld.so.conf directories ldconfig would have
+// cached are searched instead.
+func uncachedLoaderDirs(cache []LdCacheEntry) []string {
+	if sysroot == "" || len(cache) > 0 {
+		return nil
+	}
+	return ReadLdSoConf(rootRealPath(ldSoConf))
+}
+
+// elfRunPath returns the run-time search directories an ELF file names, with
+// $ORIGIN expanded to its directory, mapped into the root
+func elfRunPath(f *elf.File, path string) []string {
+	paths, _ := f.DynString(elf.DT_RUNPATH)
+	if len(paths) == 0 {
+		paths, _ = f.DynString(elf.DT_RPATH)
+	}
+	origin, ok := pathInRoot(sysroot, filepath.Dir(path))
+	if !ok {
+		origin = filepath.Dir(path)
+	}
+	var dirs []string
+	for _, p := range paths {
+		for _, dir := range filepath.SplitList(p) {
+			dir = strings.ReplaceAll(dir, "${ORIGIN}", origin)
+			dir = strings.ReplaceAll(dir, "$ORIGIN", origin)
+			if dir != "" {
+				dirs = append(dirs, rootRealPath(dir))
+			}
+		}
+	}
+	return dirs
+}
+
+// rootOwnerNames names a file's owner and group from the root's /etc/passwd
+// and /etc/group rather than the host's user database
+func rootOwnerNames(fi *FileInfo, info os.FileInfo) {
+	uid, gid, ok := fileOwnership(info)
+	if !ok {
+		return
+	}
+	fi.Owner = fmt.Sprintf("uid:%d", uid)
+	if name := idFileName(rootRealPath("/etc/passwd"), uid); name != "" {
+		fi.Owner = name
+	}
+	fi.Group = fmt.Sprintf("gid:%d", gid)
+	if name := idFileName(rootRealPath("/etc/group"), gid); name != "" {
+		fi.Group = name
+	}
+}
+
+// idFileName looks up an id in a passwd or group file, where lines are
+// "name:password:id:..."
+func idFileName(file string, id uint32) string {
+	lines, _ := readLines(file)
+	want := strconv.FormatUint(uint64(id), 10)
+	for _, line := range lines {
+		fields := strings.Split(line, ":")
+		if len(fields) >= 3 && fields[2] == want {
+			return fields[0]
+		}
+	}
+	return ""
+}
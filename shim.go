@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ShimInfo describes a version-manager shim and the executable it would run
+type ShimInfo struct {
+	Manager string // pyenv, rbenv, asdf, mise or nvm
+	Shim    string
+	Tool    string // asdf plugin or mise tool, e.g. "python"
+	Version string
+	Source  string // version file or environment variable that selected Version
+	Target  string // empty when it could not be resolved
+	Problem string // why Target is empty, or for nvm why Requested isn't what runs
+
+	Requested string // nvm only: the version .nvmrc or the default alias asks for
+}
+
+// toolVersion is a tool's requested versions and where they were set
+type toolVersion struct {
+	tool     string
+	versions []string
+	source   string
+}
+
+// ResolveShim recognises a pyenv, rbenv, asdf, mise or nvm shim and follows
+// it to the concrete executable, using the same version files as the manager.
+// system returns the next executable in PATH outside skipDir, for versions
+// set to "system".
+// It returns nil when path is not a shim.
+func ResolveShim(path, name string, system func(skipDir string) string) *ShimInfo {
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "/"
+	}
+	home, _ := os.UserHomeDir()
+
+	dir := filepath.Dir(path)
+	root := filepath.Dir(dir)
+
+	// nvm has no shims, but puts one node version's bin directory in PATH
+	if nvmDir := envOr("NVM_DIR", filepath.Join(home, ".nvm")); strings.HasPrefix(path, filepath.Join(nvmDir, "versions", "node")+"/") {
+		return resolveNvm(nvmDir, path, name, cwd, system)
+	}
+	if filepath.Base(dir) != "shims" {
+		return nil
+	}
+
+	switch shimManager(path, root) {
+	case "pyenv":
+		return resolveVersionDir(&ShimInfo{Manager: "pyenv", Shim: path}, root, "PYENV_VERSION", ".python-version", name, cwd, system)
+	case "rbenv":
+		return resolveVersionDir(&ShimInfo{Manager: "rbenv", Shim: path}, root, "RBENV_VERSION", ".ruby-version", name, cwd, system)
+	case "asdf":
+		shim := &ShimInfo{Manager: "asdf", Shim: path}
+		plugins := asdfPlugins(path)
+		var tools []toolVersion
+		for _, tv := range configuredTools(cwd, home, "ASDF", []string{".tool-versions"}, "") {
+			if len(plugins) == 0 || containsString(plugins, tv.tool) {
+				tools = append(tools, tv)
+			}
+		}
+		return resolveTools(shim, filepath.Join(root, "installs"), name, tools, system)
+	case "mise":
+		shim := &ShimInfo{Manager: "mise", Shim: path}
+		global := filepath.Join(envOr("XDG_CONFIG_HOME", filepath.Join(home, ".config")), "mise", "config.toml")
+		tools := configuredTools(cwd, home, "MISE", []string{"mise.local.toml", "mise.toml", ".mise.toml", ".config/mise.toml", ".tool-versions"}, global)
+		return resolveTools(shim, filepath.Join(root, "installs"), name, tools, system)
+	}
+	return nil
+}
+
+// shimManager works out which version manager wrote a shim
+func shimManager(path, root string) string {
+	// mise shims are symlinks to the mise binary
+	if target, err := os.Readlink(path); err == nil && filepath.Base(target) == "mise" {
+		return "mise"
+	}
+
+	head := make([]byte, 4096)
+	if f, err := os.Open(path); err == nil {
+		n, _ := f.Read(head)
+		head = head[:n]
+		_ = f.Close()
+	}
+	for _, manager := range []string{"pyenv", "rbenv", "asdf", "mise"} {
+		if bytes.Contains(head, []byte(manager)) {
+			return manager
+		}
+	}
+
+	// Fall back to the name of the manager's root directory
+	switch base := strings.TrimPrefix(filepath.Base(root), "."); base {
+	case "pyenv", "rbenv", "asdf", "mise":
+		return base
+	}
+	return ""
+}
+
+// resolveVersionDir follows a pyenv or rbenv shim: the version comes from the
+// environment, the nearest version file, then the global version file, and
+// lives in ROOT/versions/VERSION/bin
+func resolveVersionDir(shim *ShimInfo, root, envVar, file, name, cwd string, system func(string) string) *ShimInfo {
+	var versions []string
+	switch {
+	case os.Getenv(envVar) != "":
+		versions, shim.Source = strings.Split(os.Getenv(envVar), ":"), envVar
+	default:
+		if local := findUp(cwd, file); local != "" {
+			versions, shim.Source = readVersionFile(local), local
+		} else if global := readVersionFile(filepath.Join(root, "version")); len(global) > 0 {
+			versions, shim.Source = global, filepath.Join(root, "version")
+		} else {
+			versions, shim.Source = []string{"system"}, "default"
+		}
+	}
+	shim.Version = strings.Join(versions, ":")
+
+	// Several versions may be active; the first that provides the command wins
+	for _, v := range versions {
+		if target := versionTarget(filepath.Join(root, "versions"), v, name, filepath.Dir(shim.Shim), system); target != "" {
+			shim.Version, shim.Target = v, target
+			return shim
+		}
+	}
+	shim.Problem = fmt.Sprintf("'%s' is not installed for version %s", name, shim.Version)
+	return shim
+}
+
+// resolveTools follows an asdf or mise shim through the configured tools,
+// closest first, until one of them provides the command
+func resolveTools(shim *ShimInfo, installs, name string, tools []toolVersion, system func(string) string) *ShimInfo {
+	for _, tv := range tools {
+		for _, v := range tv.versions {
+			if target := versionTarget(filepath.Join(installs, tv.tool), v, name, filepath.Dir(shim.Shim), system); target != "" {
+				shim.Tool, shim.Version, shim.Source, shim.Target = tv.tool, v, tv.source, target
+				return shim
+			}
+		}
+	}
+	if len(tools) > 0 {
+		shim.Tool, shim.Version, shim.Source = tools[0].tool, strings.Join(tools[0].versions, " "), tools[0].source
+	}
+	shim.Problem = fmt.Sprintf("no configured tool version provides '%s'", name)
+	return shim
+}
+
+// versionTarget finds name in an installed version; "system" defers to PATH
+// and "path:DIR" to a local build
+func versionTarget(versionsDir, version, name, shimDir string, system func(string) string) string {
+	switch {
+	case version == "system":
+		return system(shimDir)
+	case strings.HasPrefix(version, "path:"):
+		return executableAt(filepath.Join(strings.TrimPrefix(version, "path:"), "bin", name))
+	}
+	installed := installedVersion(versionsDir, version)
+	if installed == "" {
+		return ""
+	}
+	return executableAt(filepath.Join(versionsDir, installed, "bin", name))
+}
+
+// resolveNvm reports the node version nvm put in PATH. nvm only switches
+// versions on "nvm use", so the PATH entry stays the target and the version
+// named by .nvmrc or the default alias is shown as requested.
+func resolveNvm(nvmDir, path, name, cwd string, system func(string) string) *ShimInfo {
+	versionsDir := filepath.Join(nvmDir, "versions", "node")
+	running, _, _ := strings.Cut(strings.TrimPrefix(path, versionsDir+"/"), "/")
+	shim := &ShimInfo{Manager: "nvm", Shim: path, Tool: "node", Version: running, Target: path}
+
+	if rc := findUp(cwd, ".nvmrc"); rc != "" {
+		shim.Source = rc
+		if v := readVersionFile(rc); len(v) > 0 {
+			shim.Requested = v[0]
+		}
+	} else if v := readVersionFile(filepath.Join(nvmDir, "alias", "default")); len(v) > 0 {
+		shim.Requested, shim.Source = v[0], filepath.Join(nvmDir, "alias", "default")
+	}
+	if shim.Requested == "" {
+		return shim
+	}
+
+	// Aliases (lts/*, default, user-defined) point at other aliases or versions
+	version := shim.Requested
+	for range 5 {
+		alias := readVersionFile(filepath.Join(nvmDir, "alias", version))
+		if len(alias) == 0 {
+			break
+		}
+		version = alias[0]
+	}
+
+	switch {
+	case version == "system":
+		if system(versionsDir) == "" {
+			shim.Problem = fmt.Sprintf("'%s' is not installed outside nvm", name)
+		} else {
+			shim.Problem = "nvm use would switch to the system node"
+		}
+		return shim
+	case version == "node" || version == "stable":
+		version = ""
+	case !strings.HasPrefix(version, "v"):
+		version = "v" + version
+	}
+	switch installed := installedVersion(versionsDir, version); {
+	case installed == "":
+		shim.Problem = fmt.Sprintf("node %s is not installed", shim.Requested)
+	case installed != running:
+		shim.Problem = "nvm use would switch to " + installed
+	}
+	return shim
+}
+
+// configuredTools collects the tool versions in effect in cwd: PREFIX_TOOL_VERSION
+// variables, then the nearest config file naming each tool, then global
+func configuredTools(cwd, home, envPrefix string, files []string, global string) []toolVersion {
+	var tools []toolVersion
+	seen := make(map[string]bool)
+	add := func(found []toolVersion) {
+		for _, tv := range found {
+			if !seen[tv.tool] {
+				seen[tv.tool] = true
+				tools = append(tools, tv)
+			}
+		}
+	}
+
+	var env []toolVersion
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		tool, ok := strings.CutPrefix(key, envPrefix+"_")
+		if tool, ok = strings.CutSuffix(tool, "_VERSION"); ok && tool != "" && tool != "VERSION" && value != "" {
+			tool = strings.ToLower(tool)
+			env = append(env, toolVersion{tool: tool, versions: strings.Fields(value), source: key})
+		}
+	}
+	sort.Slice(env, func(i, j int) bool { return env[i].tool < env[j].tool })
+	add(env)
+
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		for _, file := range files {
+			add(parseToolFile(filepath.Join(dir, file)))
+		}
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	if home != "" && cwd != home && !strings.HasPrefix(cwd, home+string(filepath.Separator)) {
+		for _, file := range files {
+			add(parseToolFile(filepath.Join(home, file)))
+		}
+	}
+	if global != "" {
+		add(parseToolFile(global))
+	}
+	return tools
+}
+
+// parseToolFile reads an asdf .tool-versions file or the [tools] table of a
+// mise TOML config
+func parseToolFile(path string) []toolVersion {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var tools []toolVersion
+	isTOML := strings.HasSuffix(path, ".toml")
+	inTools := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !isTOML {
+			if fields := strings.Fields(line); len(fields) >= 2 {
+				tools = append(tools, toolVersion{tool: fields[0], versions: fields[1:], source: path})
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			inTools = line == "[tools]"
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !inTools || !ok {
+			continue
+		}
+		tool := strings.Trim(strings.TrimSpace(key), `"'`)
+		if versions := tomlVersions(strings.TrimSpace(value)); len(versions) > 0 {
+			tools = append(tools, toolVersion{tool: tool, versions: versions, source: path})
+		}
+	}
+	return tools
+}
+
+// tomlVersions reads a mise tool value: "3.12", ["3.12", "3.11"] or { version = "3.12" }
+func tomlVersions(value string) []string {
+	if strings.HasPrefix(value, "{") {
+		for _, field := range strings.Split(strings.Trim(value, "{}"), ",") {
+			if key, v, ok := strings.Cut(field, "="); ok && strings.TrimSpace(key) == "version" {
+				value = strings.TrimSpace(v)
+			}
+		}
+	}
+	var versions []string
+	for _, v := range strings.Split(strings.Trim(value, "[]"), ",") {
+		if v = strings.Trim(strings.TrimSpace(v), `"'`); v != "" {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// asdfPlugins reads the "# asdf-plugin: NAME VERSION" lines asdf writes into shims
+func asdfPlugins(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var plugins []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "# asdf-plugin: "); ok {
+			if fields := strings.Fields(rest); len(fields) > 0 && !containsString(plugins, fields[0]) {
+				plugins = append(plugins, fields[0])
+			}
+		}
+	}
+	return plugins
+}
+
+// readVersionFile returns the versions listed in a version file, one or more
+// per line, ignoring comments
+func readVersionFile(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var versions []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		versions = append(versions, strings.Fields(line)...)
+	}
+	return versions
+}
+
+// findUp returns the path of name in dir or its closest parent, or ""
+func findUp(dir, name string) string {
+	for {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// installedVersion finds want in versionsDir, or else the newest installed
+// version it is a prefix of ("3.11" matches "3.11.7"); an empty want matches
+// the newest of all
+func installedVersion(versionsDir, want string) string {
+	if want != "" {
+		if info, err := os.Stat(filepath.Join(versionsDir, want)); err == nil && info.IsDir() {
+			return want
+		}
+	}
+	entries, err := os.ReadDir(versionsDir)
+	if err != nil {
+		return ""
+	}
+	best := ""
+	for _, e := range entries {
+		name := e.Name()
+		if want != "" && !strings.HasPrefix(name, want+".") {
+			continue
+		}
+		if best == "" || compareVersions(name, best) > 0 {
+			best = name
+		}
+	}
+	return best
+}
+
+// compareVersions orders dotted version strings numerically, so 3.10 sorts
+// after 3.9; non-numeric parts compare as strings
+func compareVersions(a, b string) int {
+	pa := strings.FieldsFunc(strings.TrimPrefix(a, "v"), versionSeparator)
+	pb := strings.FieldsFunc(strings.TrimPrefix(b, "v"), versionSeparator)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		switch {
+		case errA == nil && errB == nil && na != nb:
+			if na < nb {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && pa[i] != pb[i]:
+			return strings.Compare(pa[i], pb[i])
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+// versionSeparator splits versions on dots, dashes, plus signs and underscores
+func versionSeparator(r rune) bool {
+	return r == '.' || r == '-' || r == '+' || r == '_'
+}
+
+// executableAt returns path if it is an executable file
+func executableAt(path string) string {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode().Perm()&0o111 != 0 {
+		return path
+	}
+	return ""
+}
+
+// envOr returns an environment variable, or fallback when it is unset
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// FormatShimInfo renders a shim and the executable it resolves to
+func FormatShimInfo(s *ShimInfo, treeFn, pathFn, warnFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	manager := s.Manager
+	if s.Tool != "" {
+		manager += " (" + s.Tool + ")"
+	}
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Manager :"), valueFn(manager))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Shim    :"), valueFn(s.Shim))
+	switch {
+	case s.Requested != "":
+		fmt.Fprintf(&sb, "  %s %s %s %s\n", treeFn("├─"), treeFn("Version :"), valueFn(s.Version), treeFn("(from PATH)"))
+		requested := fmt.Sprintf("  %s %s %s %s", treeFn("├─"), treeFn("Request :"), valueFn(s.Requested), treeFn("(from "+s.Source+")"))
+		if s.Problem != "" {
+			requested += " " + warnFn(s.Problem)
+		}
+		sb.WriteString(requested + "\n")
+	case s.Version != "" && s.Source != "":
+		fmt.Fprintf(&sb, "  %s %s %s %s\n", treeFn("├─"), treeFn("Version :"), valueFn(s.Version), treeFn("(from "+s.Source+")"))
+	case s.Version != "":
+		fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Version :"), valueFn(s.Version))
+	}
+	if s.Target != "" {
+		fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("╰─"), treeFn("Target  :"), pathFn(s.Target))
+	} else {
+		fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("╰─"), treeFn("Target  :"), warnFn("unresolved, "+s.Problem))
+	}
+	return sb.String()
+}
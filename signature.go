@@ -0,0 +1,553 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// SignatureFile is an explicit detached signature to verify (--verify-sig)
+var SignatureFile string
+
+// SignatureKey is the public key file (or inline key) used to verify signatures (--key)
+var SignatureKey string
+
+// SignatureInfo contains the result of verifying a detached signature
+type SignatureInfo struct {
+	Path    string // signature file
+	Format  string // "minisign" or "ssh"
+	KeyID   string // minisign key id or SSH key fingerprint
+	Comment string // minisign trusted comment or SSH namespace
+	Signer  string // principal from an allowed_signers file, if known
+	Checked bool   // false when no public key was available
+	Valid   bool
+	Error   string // why verification failed or was skipped
+}
+
+// errNoSignatureKey means a signature was found but there is nothing to verify it with
+var errNoSignatureKey = errors.New("not verified (no public key, use --key)")
+
+// signatureSuffixes are the neighbor files checked during auto-discovery
+var signatureSuffixes = []string{".minisig", ".sig"}
+
+// FindSignature returns the signature file for path: SignatureFile if set,
+// otherwise the first existing neighbor such as path.minisig or path.sig
+func FindSignature(path string) string {
+	if SignatureFile != "" {
+		return SignatureFile
+	}
+	for _, suffix := range signatureSuffixes {
+		if info, err := os.Stat(path + suffix); err == nil && info.Mode().IsRegular() {
+			return path + suffix
+		}
+	}
+	return ""
+}
+
+// VerifySignature verifies a detached minisign or SSH signature of path.
+// It never returns an error for a bad signature; the reason is recorded in SignatureInfo.
+func VerifySignature(path, sigPath, keyPath string) (*SignatureInfo, error) {
+	data, err := os.ReadFile(sigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &SignatureInfo{Path: sigPath}
+	var verifyErr error
+	switch {
+	case bytes.HasPrefix(data, []byte("untrusted comment:")):
+		info.Format = "minisign"
+		verifyErr = verifyMinisign(path, data, keyPath, info)
+	case bytes.Contains(data, []byte("-----BEGIN SSH SIGNATURE-----")):
+		info.Format = "ssh"
+		verifyErr = verifySSHSig(path, data, keyPath, info)
+	default:
+		return nil, fmt.Errorf("unsupported signature format in %s", sigPath)
+	}
+
+	info.Checked = !errors.Is(verifyErr, errNoSignatureKey)
+	if verifyErr != nil {
+		info.Error = verifyErr.Error()
+	} else {
+		info.Valid = true
+	}
+	return info, nil
+}
+
+// readKeyMaterial reads a key file, or treats the argument as an inline key
+// when no such file exists and it parses as one
+func readKeyMaterial(keyPath string) ([]byte, error) {
+	if keyPath == "" {
+		return nil, errNoSignatureKey
+	}
+	data, err := os.ReadFile(keyPath)
+	if err == nil {
+		return data, nil
+	}
+	if os.IsNotExist(err) && isInlineKey(keyPath) {
+		return []byte(keyPath), nil
+	}
+	return nil, err
+}
+
+// isInlineKey reports whether s is a minisign base64 key or an SSH public key
+// line rather than a file name
+func isInlineKey(s string) bool {
+	if _, _, err := parseMinisignPublicKey([]byte(s)); err == nil {
+		return true
+	}
+	fields := strings.Fields(s)
+	for i := 0; i+1 < len(fields); i++ {
+		if _, _, err := parseSSHKeyFields(fields[i], fields[i+1]); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// minisign
+
+const (
+	minisignAlgEd       = "Ed" // signature over the raw file
+	minisignAlgPrehash  = "ED" // signature over BLAKE2b-512 of the file
+	minisignKeyIDLength = 8
+)
+
+// verifyMinisign verifies a minisign signature and its trusted comment
+func verifyMinisign(path string, sigData []byte, keyPath string, info *SignatureInfo) error {
+	lines := nonEmptyLines(string(sigData))
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("malformed minisign signature")
+	}
+
+	sigBlob, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sigBlob) != 2+minisignKeyIDLength+ed25519.SignatureSize {
+		return errors.New("malformed minisign signature")
+	}
+	alg := string(sigBlob[:2])
+	keyID := sigBlob[2 : 2+minisignKeyIDLength]
+	sig := sigBlob[2+minisignKeyIDLength:]
+	info.KeyID = minisignKeyID(keyID)
+	info.Comment = strings.TrimPrefix(lines[2], "trusted comment: ")
+
+	globalSig, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return errors.New("malformed minisign global signature")
+	}
+
+	keyData, err := readKeyMaterial(keyPath)
+	if err != nil {
+		return err
+	}
+	pubKey, pubKeyID, err := parseMinisignPublicKey(keyData)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(pubKeyID, keyID) {
+		return fmt.Errorf("signed by key %s, but public key is %s", info.KeyID, minisignKeyID(pubKeyID))
+	}
+
+	var message []byte
+	switch alg {
+	case minisignAlgEd:
+		message, err = os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+	case minisignAlgPrehash:
+		h, _ := blake2b.New512(nil)
+		if err := hashFile(path, h); err != nil {
+			return err
+		}
+		message = h.Sum(nil)
+	default:
+		return fmt.Errorf("unsupported minisign algorithm '%s'", alg)
+	}
+
+	if !ed25519.Verify(pubKey, message, sig) {
+		return errors.New("signature does not match file")
+	}
+	// The global signature covers the file signature and the trusted comment
+	if !ed25519.Verify(pubKey, append(append([]byte{}, sig...), info.Comment...), globalSig) {
+		return errors.New("trusted comment signature is invalid")
+	}
+	return nil
+}
+
+// parseMinisignPublicKey parses a minisign public key file or bare base64 key
+func parseMinisignPublicKey(data []byte) (ed25519.PublicKey, []byte, error) {
+	for _, line := range nonEmptyLines(string(data)) {
+		if strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+		blob, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(blob) != 2+minisignKeyIDLength+ed25519.PublicKeySize || string(blob[:2]) != minisignAlgEd {
+			return nil, nil, errors.New("malformed minisign public key")
+		}
+		return ed25519.PublicKey(blob[2+minisignKeyIDLength:]), blob[2 : 2+minisignKeyIDLength], nil
+	}
+	return nil, nil, errors.New("malformed minisign public key")
+}
+
+// minisignKeyID formats a key id the way minisign prints it (little-endian hex)
+func minisignKeyID(id []byte) string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(id))
+}
+
+// SSH signatures (ssh-keygen -Y sign)
+
+const sshSigMagic = "SSHSIG"
+
+// sshSigNamespace is the namespace ssh-keygen -Y sign uses for files (-n file).
+// Signatures made for git commits or other namespaces must not pass as file
+// signatures.
+const sshSigNamespace = "file"
+
+// verifySSHSig verifies an armored SSHSIG signature made with an ed25519 key
+func verifySSHSig(path string, sigData []byte, keyPath string, info *SignatureInfo) error {
+	blob, err := decodeSSHArmor(string(sigData))
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(blob, []byte(sshSigMagic)) {
+		return errors.New("malformed SSH signature")
+	}
+
+	r := &sshReader{buf: blob[len(sshSigMagic):]}
+	version := r.uint32()
+	sigKey := r.string()
+	namespace := r.string()
+	reserved := r.string()
+	hashAlg := r.string()
+	sigBlob := r.string()
+	if r.err != nil {
+		return errors.New("malformed SSH signature")
+	}
+	if version != 1 {
+		return fmt.Errorf("unsupported SSH signature version %d", version)
+	}
+
+	info.KeyID = sshFingerprint(sigKey)
+	info.Comment = "namespace: " + string(namespace)
+	if string(namespace) != sshSigNamespace {
+		return fmt.Errorf("signed for namespace '%s', not '%s'", namespace, sshSigNamespace)
+	}
+
+	keyType, pubKey, err := parseSSHWireKey(sigKey)
+	if err != nil {
+		return err
+	}
+	if keyType != "ssh-ed25519" {
+		return fmt.Errorf("unsupported SSH key type '%s'", keyType)
+	}
+
+	sr := &sshReader{buf: sigBlob}
+	sigType := sr.string()
+	sig := sr.string()
+	if sr.err != nil || string(sigType) != "ssh-ed25519" || len(sig) != ed25519.SignatureSize {
+		return errors.New("malformed SSH signature")
+	}
+
+	// The signer's key must be one of the trusted keys
+	keyData, err := readKeyMaterial(keyPath)
+	if err != nil {
+		return err
+	}
+	signer, trusted := findSSHKey(keyData, sigKey)
+	if !trusted {
+		return fmt.Errorf("signed by %s, which is not in %s", info.KeyID, keyPath)
+	}
+	if signer.Namespaces != nil && !slices.Contains(signer.Namespaces, sshSigNamespace) {
+		return fmt.Errorf("key of %s is not trusted for namespace '%s'", signer.Principal, sshSigNamespace)
+	}
+	info.Signer = signer.Principal
+
+	var h hash.Hash
+	switch string(hashAlg) {
+	case "sha512":
+		h = sha512.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return fmt.Errorf("unsupported SSH signature hash '%s'", hashAlg)
+	}
+	if err := hashFile(path, h); err != nil {
+		return err
+	}
+
+	var signed bytes.Buffer
+	signed.WriteString(sshSigMagic)
+	writeSSHString(&signed, namespace)
+	writeSSHString(&signed, reserved)
+	writeSSHString(&signed, hashAlg)
+	writeSSHString(&signed, h.Sum(nil))
+
+	if !ed25519.Verify(ed25519.PublicKey(pubKey), signed.Bytes(), sig) {
+		return errors.New("signature does not match file")
+	}
+	return nil
+}
+
+// decodeSSHArmor strips the BEGIN/END lines from an armored SSH signature
+func decodeSSHArmor(s string) ([]byte, error) {
+	var b64 strings.Builder
+	inside := false
+	for _, line := range nonEmptyLines(s) {
+		switch {
+		case line == "-----BEGIN SSH SIGNATURE-----":
+			inside = true
+		case line == "-----END SSH SIGNATURE-----":
+			inside = false
+		case inside:
+			b64.WriteString(line)
+		}
+	}
+	blob, err := base64.StdEncoding.DecodeString(b64.String())
+	if err != nil {
+		return nil, errors.New("malformed SSH signature armor")
+	}
+	return blob, nil
+}
+
+// parseSSHWireKey parses a public key in SSH wire format (type, key bytes)
+func parseSSHWireKey(blob []byte) (string, []byte, error) {
+	r := &sshReader{buf: blob}
+	keyType := r.string()
+	key := r.string()
+	if r.err != nil {
+		return "", nil, errors.New("malformed SSH public key")
+	}
+	if string(keyType) == "ssh-ed25519" && len(key) != ed25519.PublicKeySize {
+		return "", nil, errors.New("malformed ed25519 public key")
+	}
+	return string(keyType), key, nil
+}
+
+// sshTrustedKey is a matching line of an authorized_keys or allowed_signers file
+type sshTrustedKey struct {
+	Principal  string   // allowed_signers principals; empty for authorized_keys
+	Namespaces []string // namespaces="..." restriction, nil when unrestricted
+}
+
+// sshKeyOptions are the option names authorized_keys and allowed_signers lines
+// may start with, before the key type
+var sshKeyOptions = map[string]bool{
+	"agent-forwarding": true, "cert-authority": true, "command": true, "environment": true,
+	"expiry-time": true, "from": true, "namespaces": true, "no-agent-forwarding": true,
+	"no-port-forwarding": true, "no-pty": true, "no-touch-required": true, "no-user-rc": true,
+	"no-x11-forwarding": true, "permitlisten": true, "permitopen": true, "port-forwarding": true,
+	"principals": true, "pty": true, "restrict": true, "tunnel": true, "user-rc": true,
+	"valid-after": true, "valid-before": true, "verify-required": true, "x11-forwarding": true,
+}
+
+// findSSHKey looks for a key in authorized_keys or allowed_signers format:
+// "[principals] [options] keytype base64-key [comment]".
+func findSSHKey(keyData, wireKey []byte) (sshTrustedKey, bool) {
+	for _, line := range nonEmptyLines(string(keyData)) {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := splitSSHKeyLine(line)
+		for i := 0; i+1 < len(fields); i++ {
+			_, blob, err := parseSSHKeyFields(fields[i], fields[i+1])
+			if err != nil || !bytes.Equal(blob, wireKey) {
+				continue
+			}
+			var key sshTrustedKey
+			for _, field := range fields[:i] {
+				opts, ok := parseSSHKeyOptions(field)
+				if !ok {
+					if key.Principal == "" {
+						key.Principal = field
+					}
+					continue
+				}
+				if ns, ok := opts["namespaces"]; ok {
+					key.Namespaces = strings.Split(ns, ",")
+				}
+			}
+			return key, true
+		}
+	}
+	return sshTrustedKey{}, false
+}
+
+// parseSSHKeyFields decodes a key type and base64 key pair into wire format
+func parseSSHKeyFields(keyType, b64 string) (string, []byte, error) {
+	if !strings.HasPrefix(keyType, "ssh-") && !strings.HasPrefix(keyType, "sk-") && !strings.HasPrefix(keyType, "ecdsa-") {
+		return "", nil, errors.New("not an SSH key type")
+	}
+	blob, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", nil, err
+	}
+	wireType, _, err := parseSSHWireKey(blob)
+	if err != nil || wireType != keyType {
+		return "", nil, errors.New("malformed SSH public key")
+	}
+	return keyType, blob, nil
+}
+
+// splitSSHKeyLine splits a key file line on whitespace, keeping quoted option
+// values such as command="a b" in one field
+func splitSSHKeyLine(line string) []string {
+	var fields []string
+	var cur strings.Builder
+	quoted := false
+	for _, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			cur.WriteRune(c)
+		case (c == ' ' || c == '\t') && !quoted:
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+// parseSSHKeyOptions parses a comma-separated option list such as
+// no-pty,namespaces="file,git", reporting false when field is not one
+func parseSSHKeyOptions(field string) (map[string]string, bool) {
+	opts := make(map[string]string)
+	var items []string
+	start, quoted := 0, false
+	for i, c := range field {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			items = append(items, field[start:i])
+			start = i + 1
+		}
+	}
+	items = append(items, field[start:])
+	for _, item := range items {
+		name, value, _ := strings.Cut(item, "=")
+		if !sshKeyOptions[strings.ToLower(name)] {
+			return nil, false
+		}
+		opts[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+	return opts, true
+}
+
+// sshFingerprint formats a key fingerprint the way ssh-keygen -l does
+func sshFingerprint(wireKey []byte) string {
+	sum := sha256.Sum256(wireKey)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// sshReader decodes SSH wire-format fields, remembering the first error
+type sshReader struct {
+	buf []byte
+	err error
+}
+
+func (r *sshReader) uint32() uint32 {
+	if r.err != nil || len(r.buf) < 4 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v
+}
+
+func (r *sshReader) string() []byte {
+	n := r.uint32()
+	if r.err != nil || uint32(len(r.buf)) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	s := r.buf[:n]
+	r.buf = r.buf[n:]
+	return s
+}
+
+// writeSSHString writes a length-prefixed SSH wire-format string
+func writeSSHString(buf *bytes.Buffer, s []byte) {
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.Write(s)
+}
+
+// hashFile streams a file into h
+func hashFile(path string, h hash.Hash) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	_, err = io.Copy(h, &contextReader{ctx: HashContext, r: file})
+	return err
+}
+
+// nonEmptyLines splits text into non-blank lines. Only line endings are trimmed,
+// since minisign trusted comments are signed byte-for-byte.
+func nonEmptyLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// FormatSignatureInfo formats signature verification results for display with colors
+func FormatSignatureInfo(info *SignatureInfo, labelFn, treeFn, valueFn, okFn, badFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+
+	rows := [][2]string{
+		{"File    :", valueFn(info.Path)},
+		{"Format  :", valueFn(info.Format)},
+	}
+	if info.KeyID != "" {
+		rows = append(rows, [2]string{"Key ID  :", valueFn(info.KeyID)})
+	}
+	if info.Signer != "" {
+		rows = append(rows, [2]string{"Signer  :", valueFn(info.Signer)})
+	}
+	if info.Comment != "" {
+		rows = append(rows, [2]string{"Comment :", valueFn(info.Comment)})
+	}
+	switch {
+	case info.Valid:
+		rows = append(rows, [2]string{"Status  :", okFn("✓ valid")})
+	case !info.Checked:
+		rows = append(rows, [2]string{"Status  :", valueFn(info.Error)})
+	default:
+		rows = append(rows, [2]string{"Status  :", badFn("✗ " + info.Error)})
+	}
+
+	for i, row := range rows {
+		branch := "├─"
+		if i == len(rows)-1 {
+			branch = "╰─"
+		}
+		fmt.Fprintf(&sb, "  %s %s %s\n", treeFn(branch), labelFn(row[0]), row[1])
+	}
+	return sb.String()
+}
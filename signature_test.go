@@ -0,0 +1,196 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// The fixtures in testdata/signature sign message.txt: the .minisig files with
+// the key in minisign.pub, the .sig files with ssh-keygen -Y sign
+var sigDir = filepath.Join("testdata", "signature")
+
+// sigFixture returns the path of a file in testdata/signature
+func sigFixture(name string) string {
+	return filepath.Join(sigDir, name)
+}
+
+// tamperedMessage copies message.txt with one byte changed
+func tamperedMessage(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(sigFixture("message.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[0] ^= 0x20
+	path := filepath.Join(t.TempDir(), "message.txt")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	message := sigFixture("message.txt")
+	tests := []struct {
+		name, file, sig, key string
+		wantErr              string // empty for a valid signature
+	}{
+		{"prehashed", message, "message.txt.minisig", sigFixture("minisign.pub"), ""},
+		{"legacy", message, "legacy.minisig", sigFixture("minisign.pub"), ""},
+		{"wrong key", message, "message.txt.minisig", sigFixture("other.pub"), "signed by key E901D46ADDEA1A4C"},
+		{"edited trusted comment", message, "comment.minisig", sigFixture("minisign.pub"), "trusted comment signature is invalid"},
+		{"modified file", tamperedMessage(t), "message.txt.minisig", sigFixture("minisign.pub"), "signature does not match file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := VerifySignature(tt.file, sigFixture(tt.sig), tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Format != "minisign" || info.KeyID != "E901D46ADDEA1A4C" || !info.Checked {
+				t.Errorf("got format %s, key %s, checked %v", info.Format, info.KeyID, info.Checked)
+			}
+			if tt.wantErr == "" {
+				if !info.Valid || info.Error != "" {
+					t.Errorf("want a valid signature, got %q", info.Error)
+				}
+				if !strings.HasPrefix(info.Comment, "timestamp:1700000000\tfile:message.txt") {
+					t.Errorf("Comment = %q", info.Comment)
+				}
+				return
+			}
+			if info.Valid || !strings.Contains(info.Error, tt.wantErr) {
+				t.Errorf("got valid=%v error %q, want an error containing %q", info.Valid, info.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifySSHSig(t *testing.T) {
+	message := sigFixture("message.txt")
+	tests := []struct {
+		name, file, sig, key string
+		signer               string
+		wantErr              string
+	}{
+		{"allowed_signers", message, "message.txt.sig", sigFixture("allowed_signers"), "alice@example.com", ""},
+		{"authorized_keys with options", message, "message.txt.sig", sigFixture("authorized_keys"), "", ""},
+		{"git namespace", message, "git.sig", sigFixture("allowed_signers"), "", "signed for namespace 'git', not 'file'"},
+		{"untrusted signer", message, "mallory.sig", sigFixture("allowed_signers"), "", "which is not in"},
+		{"key restricted to git", message, "message.txt.sig", sigFixture("allowed_signers_git"), "", "not trusted for namespace 'file'"},
+		{"modified file", tamperedMessage(t), "message.txt.sig", sigFixture("allowed_signers"), "alice@example.com", "signature does not match file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := VerifySignature(tt.file, sigFixture(tt.sig), tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Format != "ssh" || !strings.HasPrefix(info.KeyID, "SHA256:") || !info.Checked {
+				t.Errorf("got format %s, key %s, checked %v", info.Format, info.KeyID, info.Checked)
+			}
+			if info.Signer != tt.signer {
+				t.Errorf("Signer = %q, want %q", info.Signer, tt.signer)
+			}
+			if tt.wantErr == "" {
+				if !info.Valid || info.Error != "" {
+					t.Errorf("want a valid signature, got %q", info.Error)
+				}
+				if info.Comment != "namespace: file" {
+					t.Errorf("Comment = %q", info.Comment)
+				}
+				return
+			}
+			if info.Valid || !strings.Contains(info.Error, tt.wantErr) {
+				t.Errorf("got valid=%v error %q, want an error containing %q", info.Valid, info.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignatureKeyArgument(t *testing.T) {
+	message := sigFixture("message.txt")
+	readLine := func(name string, line int) string {
+		data, err := os.ReadFile(sigFixture(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")[line]
+	}
+
+	// Inline keys are accepted in place of a file
+	for sig, key := range map[string]string{
+		"message.txt.minisig": readLine("minisign.pub", 1),
+		"message.txt.sig":     readLine("alice.pub", 0),
+	} {
+		info, err := VerifySignature(message, sigFixture(sig), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.Valid {
+			t.Errorf("%s with an inline key: %s", sig, info.Error)
+		}
+	}
+
+	// A mistyped file name is an error, not a key
+	info, err := VerifySignature(message, sigFixture("message.txt.minisig"), sigFixture("minisgn.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Valid || !info.Checked || !strings.Contains(info.Error, "no such file") {
+		t.Errorf("got valid=%v checked=%v error %q, want a missing file error", info.Valid, info.Checked, info.Error)
+	}
+
+	// Without a key the signature is found but not checked
+	info, err = VerifySignature(message, sigFixture("message.txt.sig"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Valid || info.Checked {
+		t.Errorf("got valid=%v checked=%v without a key", info.Valid, info.Checked)
+	}
+
+	if _, err := VerifySignature(message, message, ""); err == nil {
+		t.Error("a file that is not a signature was accepted")
+	}
+}
+
+func TestFindSignature(t *testing.T) {
+	if got, want := FindSignature(sigFixture("message.txt")), sigFixture("message.txt.minisig"); got != want {
+		t.Errorf("FindSignature = %s, want %s", got, want)
+	}
+	if got := FindSignature(sigFixture("alice.pub")); got != "" {
+		t.Errorf("FindSignature found %s for a file without a signature", got)
+	}
+}
+
+func TestSplitSSHKeyLine(t *testing.T) {
+	line := `alice@example.com namespaces="file,git",command="echo a b" ssh-ed25519 AAAA comment here`
+	want := []string{"alice@example.com", `namespaces="file,git",command="echo a b"`, "ssh-ed25519", "AAAA", "comment", "here"}
+	if got := splitSSHKeyLine(line); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseSSHKeyOptions(t *testing.T) {
+	opts, ok := parseSSHKeyOptions(`no-pty,Namespaces="file,git",command="a,b"`)
+	if !ok {
+		t.Fatal("options were not recognised")
+	}
+	if opts["namespaces"] != "file,git" || opts["command"] != "a,b" {
+		t.Errorf("got %v", opts)
+	}
+	if _, ok := opts["no-pty"]; !ok {
+		t.Errorf("flag option missing from %v", opts)
+	}
+
+	// Principals are not options
+	for _, field := range []string{"alice@example.com", "*@example.com", "no-pty,alice"} {
+		if _, ok := parseSSHKeyOptions(field); ok {
+			t.Errorf("%q was taken for an option list", field)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotVersion is bumped when the baseline format changes incompatibly
+const snapshotVersion = 1
+
+// Snapshot is a recorded baseline of files, written by `finfo snapshot`
+type Snapshot struct {
+	Version int             `json:"version"`
+	Created time.Time       `json:"created"`
+	Host    string          `json:"host,omitempty"`
+	Roots   []string        `json:"roots"`
+	Files   []SnapshotEntry `json:"files"`
+}
+
+// SnapshotEntry records the properties of one path
+type SnapshotEntry struct {
+	Path            string            `json:"path"`
+	Type            string            `json:"type"`
+	Size            int64             `json:"size"`
+	Mode            string            `json:"mode"`
+	Owner           string            `json:"owner,omitempty"`
+	Group           string            `json:"group,omitempty"`
+	ModTime         time.Time         `json:"mtime"`
+	ChangeTime      time.Time         `json:"ctime"`
+	SymlinkTarget   string            `json:"symlink_target,omitempty"`
+	SHA256          string            `json:"sha256,omitempty"`
+	SHA512          string            `json:"sha512,omitempty"`
+	Format          string            `json:"format,omitempty"`
+	MIME            string            `json:"mime,omitempty"`
+	LinkedLibraries []string          `json:"linked_libraries,omitempty"`
+	Stripped        bool              `json:"stripped,omitempty"`
+	Xattrs          map[string]string `json:"xattrs,omitempty"`
+}
+
+// DriftFields lists the properties drift compares, for --ignore
+var DriftFields = []string{
+	"type", "size", "mode", "owner", "group", "mtime", "ctime", "symlink",
+	"sha256", "sha512", "format", "mime", "libs", "stripped", "xattrs",
+}
+
+// DriftChange is one property that no longer matches the baseline
+type DriftChange struct {
+	Path   string `json:"path"`
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// DriftReport is the result of checking paths against a baseline
+type DriftReport struct {
+	Baseline string        `json:"baseline"`
+	Created  time.Time     `json:"baseline_created"`
+	Checked  int           `json:"checked"`
+	Added    []string      `json:"added"`
+	Removed  []string      `json:"removed"`
+	Changed  []DriftChange `json:"changed"`
+}
+
+// Drifted reports whether anything differs from the baseline
+func (r *DriftReport) Drifted() bool {
+	return len(r.Added)+len(r.Removed)+len(r.Changed) > 0
+}
+
+// TakeSnapshot records every file, directory and symlink under the given paths.
+// Symlinks are recorded, not followed; unreadable paths are skipped with a warning.
+func TakeSnapshot(paths []string) (*Snapshot, error) {
+	// Snapshots always include checksums
+	saved := CalculateHashesFlag
+	CalculateHashesFlag = true
+	defer func() { CalculateHashesFlag = saved }()
+
+	snap := &Snapshot{Version: snapshotVersion, Created: time.Now().UTC(), Files: []SnapshotEntry{}}
+	snap.Host, _ = os.Hostname()
+
+	seen := make(map[string]bool)
+	for _, root := range paths {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Lstat(absRoot); err != nil {
+			return nil, err
+		}
+		snap.Roots = append(snap.Roots, absRoot)
+
+		err = filepath.WalkDir(absRoot, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				return nil
+			}
+			if err := HashContext.Err(); err != nil {
+				return err
+			}
+			if seen[path] {
+				return nil
+			}
+			seen[path] = true
+
+			entry, err := snapshotEntry(path)
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return err
+				}
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				return nil
+			}
+			snap.Files = append(snap.Files, *entry)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(snap.Files, func(i, j int) bool { return snap.Files[i].Path < snap.Files[j].Path })
+	return snap, nil
+}
+
+// snapshotEntry records one path. Directories, symlinks and special files are
+// described from lstat alone; regular files are inspected with GetFileInfo.
+func snapshotEntry(path string) (*SnapshotEntry, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &SnapshotEntry{
+		Path:       path,
+		Type:       fileKind(info),
+		Size:       info.Size(),
+		Mode:       info.Mode().String(),
+		ModTime:    info.ModTime().UTC(),
+		ChangeTime: fileChangeTime(info).UTC(),
+		Xattrs:     readXattrs(path),
+	}
+	if uid, gid, ok := fileOwnership(info); ok {
+		entry.Owner = idName("user", uid, getUserName)
+		entry.Group = idName("group", gid, getGroupName)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		entry.SymlinkTarget, _ = os.Readlink(path)
+	}
+	if !info.Mode().IsRegular() {
+		return entry, nil
+	}
+
+	fi, err := GetFileInfo(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.HashInfo != nil {
+		entry.SHA256 = fi.HashInfo.SHA256
+		entry.SHA512 = fi.HashInfo.SHA512
+	}
+	if fi.FileType != nil {
+		entry.Format = fi.FileType.FileFormat
+		entry.MIME = fi.FileType.MIMEType
+	}
+	if fi.BinaryInfo != nil {
+		entry.LinkedLibraries = fi.BinaryInfo.LinkedLibraries
+		entry.Stripped = fi.BinaryInfo.IsStripped
+	}
+	return entry, nil
+}
+
+// idName formats a uid or gid as a name, falling back to the number
+func idName(kind string, id uint32, lookup func(uint32) (string, error)) string {
+	if name, err := cachedName(kind, id, lookup); err == nil {
+		return name
+	}
+	if kind == "user" {
+		return fmt.Sprintf("uid:%d", id)
+	}
+	return fmt.Sprintf("gid:%d", id)
+}
+
+// LoadSnapshot reads a baseline written by `finfo snapshot`
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported baseline version %d in %s", snap.Version, path)
+	}
+	return &snap, nil
+}
+
+// CheckDrift re-inspects the roots of a baseline and reports what changed.
+// Fields named in ignore (see DriftFields) are not compared.
+func CheckDrift(baselinePath string, ignore []string) (*DriftReport, error) {
+	for _, f := range ignore {
+		if !containsString(DriftFields, f) {
+			return nil, fmt.Errorf("unknown field '%s' for --ignore (expected one of: %s)", f, strings.Join(DriftFields, ", "))
+		}
+	}
+
+	baseline, err := LoadSnapshot(baselinePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// A root that has disappeared is reported as removed, not as an error
+	var roots []string
+	for _, root := range baseline.Roots {
+		if _, err := os.Lstat(root); err == nil {
+			roots = append(roots, root)
+		}
+	}
+	current, err := TakeSnapshot(roots)
+	if err != nil {
+		return nil, err
+	}
+
+	before := make(map[string]SnapshotEntry, len(baseline.Files))
+	for _, e := range baseline.Files {
+		before[e.Path] = e
+	}
+	after := make(map[string]SnapshotEntry, len(current.Files))
+	for _, e := range current.Files {
+		after[e.Path] = e
+	}
+
+	report := &DriftReport{
+		Baseline: baselinePath,
+		Created:  baseline.Created,
+		Added:    []string{},
+		Removed:  []string{},
+		Changed:  []DriftChange{},
+	}
+	for _, path := range unionKeys(before, after) {
+		a, inBefore := before[path]
+		b, inAfter := after[path]
+		switch {
+		case !inBefore:
+			report.Added = append(report.Added, path)
+		case !inAfter:
+			report.Removed = append(report.Removed, path)
+		default:
+			report.Checked++
+			report.Changed = append(report.Changed, compareEntries(a, b, ignore)...)
+		}
+	}
+	return report, nil
+}
+
+// compareEntries lists the properties that differ between two records of a path
+func compareEntries(a, b SnapshotEntry, ignore []string) []DriftChange {
+	var changes []DriftChange
+	add := func(field, before, after string) {
+		if before != after && !containsString(ignore, field) {
+			changes = append(changes, DriftChange{Path: a.Path, Field: field, Before: before, After: after})
+		}
+	}
+	timestamp := func(t time.Time) string {
+		return t.Format(time.RFC3339Nano)
+	}
+
+	add("type", a.Type, b.Type)
+	add("size", fmt.Sprintf("%d", a.Size), fmt.Sprintf("%d", b.Size))
+	add("mode", a.Mode, b.Mode)
+	add("owner", a.Owner, b.Owner)
+	add("group", a.Group, b.Group)
+	add("mtime", timestamp(a.ModTime), timestamp(b.ModTime))
+	add("ctime", timestamp(a.ChangeTime), timestamp(b.ChangeTime))
+	add("symlink", a.SymlinkTarget, b.SymlinkTarget)
+	add("sha256", a.SHA256, b.SHA256)
+	add("sha512", a.SHA512, b.SHA512)
+	add("format", a.Format, b.Format)
+	add("mime", a.MIME, b.MIME)
+	add("libs", strings.Join(a.LinkedLibraries, ", "), strings.Join(b.LinkedLibraries, ", "))
+	add("stripped", yesNo(a.Stripped), yesNo(b.Stripped))
+
+	if !containsString(ignore, "xattrs") {
+		for _, name := range unionKeys(a.Xattrs, b.Xattrs) {
+			before, inA := a.Xattrs[name]
+			after, inB := b.Xattrs[name]
+			if !inA {
+				before = "(none)"
+			}
+			if !inB {
+				after = "(none)"
+			}
+			if before != after {
+				changes = append(changes, DriftChange{Path: a.Path, Field: "xattr " + name, Before: before, After: after})
+			}
+		}
+	}
+	return changes
+}
+
+// FormatSnapshotJSON renders a snapshot as indented JSON
+func FormatSnapshotJSON(snap *Snapshot) (string, error) {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// FormatDriftJSON renders a drift report as indented JSON
+func FormatDriftJSON(report *DriftReport) (string, error) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// FormatDrift formats a drift report for display with colors
+func FormatDrift(report *DriftReport, labelFn, treeFn, pathFn, matchFn, diffFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s %s\n", labelFn("Baseline:"), valueFn(report.Baseline),
+		treeFn("("+report.Created.Local().Format("2006-01-02 15:04:05")+")"))
+
+	if len(report.Removed) > 0 {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn("Removed:"))
+		for _, path := range report.Removed {
+			fmt.Fprintf(&sb, "  %s %s\n", diffFn("-"), pathFn(path))
+		}
+	}
+	if len(report.Added) > 0 {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn("Added:"))
+		for _, path := range report.Added {
+			fmt.Fprintf(&sb, "  %s %s\n", matchFn("+"), pathFn(path))
+		}
+	}
+
+	if len(report.Changed) > 0 {
+		fmt.Fprintf(&sb, "\n%s\n", labelFn("Changed:"))
+		var last string
+		for i, c := range report.Changed {
+			if c.Path != last {
+				fmt.Fprintf(&sb, "  %s %s\n", diffFn("✗"), pathFn(c.Path))
+				last = c.Path
+			}
+			branch := "├─"
+			if i == len(report.Changed)-1 || report.Changed[i+1].Path != c.Path {
+				branch = "╰─"
+			}
+			fmt.Fprintf(&sb, "    %s %s %s → %s\n", treeFn(branch), treeFn(c.Field+":"), valueFn(orNone(c.Before)), valueFn(orNone(c.After)))
+		}
+	}
+
+	changedPaths := make(map[string]bool)
+	for _, c := range report.Changed {
+		changedPaths[c.Path] = true
+	}
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Summary:"))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Checked :"), valueFn(fmt.Sprintf("%d path(s)", report.Checked)))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Changed :"), valueFn(fmt.Sprintf("%d path(s), %d change(s)", len(changedPaths), len(report.Changed))))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("├─"), treeFn("Added   :"), valueFn(len(report.Added)))
+	fmt.Fprintf(&sb, "  %s %s %s\n", treeFn("╰─"), treeFn("Removed :"), valueFn(len(report.Removed)))
+
+	fmt.Fprintf(&sb, "\n%s\n", labelFn("Verdict:"))
+	if report.Drifted() {
+		fmt.Fprintf(&sb, "  %s\n", diffFn("✗ DRIFT detected since the baseline"))
+	} else {
+		fmt.Fprintf(&sb, "  %s\n", matchFn("✓ No drift since the baseline"))
+	}
+	return sb.String()
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// DiffOptions controls what --diff compares and how it is displayed
+type DiffOptions struct {
+	ContextLines int   // lines of context around each hunk
+	WordDiff     bool  // highlight changed words within changed lines
+	MaxTextSize  int64 // text files larger than this are not diffed line by line
+
+	IgnoreVolatile bool // skip ELF build-id notes and Mach-O UUIDs in binary diffs
+
+	Excludes     []string // globs skipped when comparing directories
+	MetadataOnly bool     // compare directory files by size and mtime only
+	Detail       bool     // append the per-file comparison of changed files in directories
+
+	Ignore []string // metadata fields left out of the comparison (see MetadataFields)
+	Strict bool     // differing metadata alone sets the "different" exit status
+}
+
+// DiffOpts holds the options used by CompareFiles
+var DiffOpts = DiffOptions{
+	ContextLines: 3,
+	MaxTextSize:  1024 * 1024,
+}
+
+// maxEditDistance bounds the Myers search; beyond it the remainder is treated as replaced
+const maxEditDistance = 4096
+
+// editOp is a single line-level edit
+type editOp int
+
+const (
+	opEqual editOp = iota
+	opDelete
+	opInsert
+)
+
+// lineEdit is one line of an edit script
+type lineEdit struct {
+	op   editOp
+	text string
+	a, b int // 0-based line numbers in each file
+}
+
+// DiffHunk is a group of changes with surrounding context
+type DiffHunk struct {
+	StartA, LenA int
+	StartB, LenB int
+	edits        []lineEdit
+}
+
+// TextDiff is the line-level difference between two text files
+type TextDiff struct {
+	Hunks     []DiffHunk
+	Added     int
+	Removed   int
+	noEOLA    bool
+	noEOLB    bool
+	linesA    int
+	linesB    int
+	Truncated bool // the edit distance limit was hit and the tail is shown as replaced
+}
+
+// DiffTextFiles computes a unified diff of two text files
+func DiffTextFiles(path1, path2 string, context int) (*TextDiff, error) {
+	data1, err := os.ReadFile(path1)
+	if err != nil {
+		return nil, err
+	}
+	data2, err := os.ReadFile(path2)
+	if err != nil {
+		return nil, err
+	}
+
+	a, noEOLA := splitLines(string(data1))
+	b, noEOLB := splitLines(string(data2))
+
+	// A last line without a newline only matches another such line
+	if noEOLA {
+		a[len(a)-1] += noEOLMark
+	}
+	if noEOLB {
+		b[len(b)-1] += noEOLMark
+	}
+	edits, truncated := myersDiff(a, b)
+
+	td := &TextDiff{noEOLA: noEOLA, noEOLB: noEOLB, linesA: len(a), linesB: len(b), Truncated: truncated}
+	for i, e := range edits {
+		edits[i].text = strings.TrimSuffix(e.text, noEOLMark)
+		switch e.op {
+		case opInsert:
+			td.Added++
+		case opDelete:
+			td.Removed++
+		}
+	}
+	td.Hunks = buildHunks(edits, context)
+	return td, nil
+}
+
+// noEOLMark tells a last line without a newline apart while diffing
+const noEOLMark = "\x00"
+
+// missingEOL reports whether e is the last line of a file that has no
+// trailing newline
+func (td *TextDiff) missingEOL(e lineEdit) bool {
+	return (e.op != opInsert && td.noEOLA && e.a == td.linesA-1) ||
+		(e.op != opDelete && td.noEOLB && e.b == td.linesB-1)
+}
+
+// splitLines splits text into lines and reports a missing trailing newline
+func splitLines(s string) ([]string, bool) {
+	if s == "" {
+		return nil, false
+	}
+	noEOL := !strings.HasSuffix(s, "\n")
+	s = strings.TrimSuffix(s, "\n")
+	return strings.Split(s, "\n"), noEOL
+}
+
+// myersDiff computes a shortest edit script between a and b
+func myersDiff(a, b []string) ([]lineEdit, bool) {
+	// Common prefix and suffix never need the expensive search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []lineEdit
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, lineEdit{op: opEqual, text: a[i], a: i, b: i})
+	}
+	middle, truncated := myersMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)
+	edits = append(edits, middle...)
+	for i := 0; i < suffix; i++ {
+		ia, ib := len(a)-suffix+i, len(b)-suffix+i
+		edits = append(edits, lineEdit{op: opEqual, text: a[ia], a: ia, b: ib})
+	}
+	return edits, truncated
+}
+
+// myersMiddle runs the greedy O(ND) Myers algorithm, keeping each round's
+// frontier so the path can be traced back. Round d only reaches diagonals
+// -d..d, so only those are kept: O(D²) memory rather than O(D·maxD).
+func myersMiddle(a, b []string, offA, offB int) ([]lineEdit, bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(a, b, offA, offB), false
+	}
+
+	maxD := min(n+m, maxEditDistance)
+	v := make([]int, 2*maxD+2)
+	var trace [][]int32 // trace[d][k+d] is the furthest x on diagonal k after round d
+
+	found := false
+	for d := 0; d <= maxD && !found; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[maxD+k-1] < v[maxD+k+1]) {
+				x = v[maxD+k+1]
+			} else {
+				x = v[maxD+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[maxD+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		frontier := make([]int32, 2*d+1)
+		for i := range frontier {
+			frontier[i] = int32(v[maxD-d+i])
+		}
+		trace = append(trace, frontier)
+	}
+	if !found {
+		return replaceAll(a, b, offA, offB), true
+	}
+
+	// Walk the trace backwards from (n, m)
+	var rev []lineEdit
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1] // diagonals -(d-1)..d-1
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := int(prev[prevK+d-1])
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, lineEdit{op: opEqual, text: a[x], a: offA + x, b: offB + y})
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, lineEdit{op: opInsert, text: b[y], a: offA + x, b: offB + y})
+		} else {
+			x--
+			rev = append(rev, lineEdit{op: opDelete, text: a[x], a: offA + x, b: offB + y})
+		}
+	}
+	// Round 0 is the snake from the origin
+	for x > 0 && y > 0 {
+		x--
+		y--
+		rev = append(rev, lineEdit{op: opEqual, text: a[x], a: offA + x, b: offB + y})
+	}
+
+	edits := make([]lineEdit, len(rev))
+	for i := range rev {
+		edits[i] = rev[len(rev)-1-i]
+	}
+	return edits, false
+}
+
+// replaceAll deletes every line of a and inserts every line of b
+func replaceAll(a, b []string, offA, offB int) []lineEdit {
+	var edits []lineEdit
+	for i, line := range a {
+		edits = append(edits, lineEdit{op: opDelete, text: line, a: offA + i, b: offB})
+	}
+	for i, line := range b {
+		edits = append(edits, lineEdit{op: opInsert, text: line, a: offA + len(a), b: offB + i})
+	}
+	return edits
+}
+
+// buildHunks groups edits into hunks with the given lines of context
+func buildHunks(edits []lineEdit, context int) []DiffHunk {
+	var hunks []DiffHunk
+	i := 0
+	for i < len(edits) {
+		// Find the next change
+		for i < len(edits) && edits[i].op == opEqual {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		start := max(0, i-context)
+		end := i
+		// Extend while the next change is within 2*context equal lines
+		for end < len(edits) {
+			if edits[end].op != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].op == opEqual {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end = min(len(edits), end+context)
+				break
+			}
+			end = run
+		}
+
+		h := DiffHunk{edits: edits[start:end]}
+		h.StartA, h.StartB = edits[start].a, edits[start].b
+		for _, e := range h.edits {
+			if e.op != opInsert {
+				h.LenA++
+			}
+			if e.op != opDelete {
+				h.LenB++
+			}
+		}
+		hunks = append(hunks, h)
+		i = end
+	}
+	return hunks
+}
+
+// FormatTextDiff renders a unified diff with colors
+func FormatTextDiff(td *TextDiff, wordDiff bool, labelFn, matchFn, diffFn, valueFn func(a ...interface{}) string) string {
+	var sb strings.Builder
+
+	for _, h := range td.Hunks {
+		fmt.Fprintf(&sb, "%s\n", labelFn(fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.StartA, h.LenA), hunkRange(h.StartB, h.LenB))))
+
+		for i := 0; i < len(h.edits); {
+			e := h.edits[i]
+			if e.op == opEqual {
+				fmt.Fprintf(&sb, "%s\n", valueFn(" "+e.text))
+				writeNoEOL(&sb, td, valueFn, e)
+				i++
+				continue
+			}
+
+			// Collect a block of deletions followed by insertions
+			var dels, ins []lineEdit
+			for i < len(h.edits) && h.edits[i].op == opDelete {
+				dels = append(dels, h.edits[i])
+				i++
+			}
+			for i < len(h.edits) && h.edits[i].op == opInsert {
+				ins = append(ins, h.edits[i])
+				i++
+			}
+
+			if wordDiff && len(dels) == len(ins) {
+				for j := range dels {
+					fmt.Fprintf(&sb, "%s%s\n", valueFn("~"), wordLevelDiff(dels[j].text, ins[j].text, matchFn, diffFn, valueFn))
+					writeNoEOL(&sb, td, valueFn, dels[j], ins[j])
+				}
+				continue
+			}
+			for _, d := range dels {
+				fmt.Fprintf(&sb, "%s\n", diffFn("-"+d.text))
+				writeNoEOL(&sb, td, valueFn, d)
+			}
+			for _, in := range ins {
+				fmt.Fprintf(&sb, "%s\n", matchFn("+"+in.text))
+				writeNoEOL(&sb, td, valueFn, in)
+			}
+		}
+	}
+
+	if td.Truncated {
+		fmt.Fprintf(&sb, "%s\n", valueFn("(too many changes; remaining lines shown as replaced)"))
+	}
+	return sb.String()
+}
+
+// writeNoEOL marks a line just printed as lacking a trailing newline, as
+// diff -u does, when any of the edits it shows is such a last line
+func writeNoEOL(sb *strings.Builder, td *TextDiff, valueFn func(a ...interface{}) string, edits ...lineEdit) {
+	for _, e := range edits {
+		if td.missingEOL(e) {
+			fmt.Fprintf(sb, "%s\n", valueFn(`\ No newline at end of file`))
+			return
+		}
+	}
+}
+
+// hunkRange formats a unified diff range ("start,len", 1-based)
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// wordLevelDiff merges two lines, marking removed words [-like this-] and added words {+like this+}
+func wordLevelDiff(oldLine, newLine string, matchFn, diffFn, valueFn func(a ...interface{}) string) string {
+	edits, _ := myersDiff(tokenizeWords(oldLine), tokenizeWords(newLine))
+
+	var sb strings.Builder
+	for i := 0; i < len(edits); {
+		switch edits[i].op {
+		case opEqual:
+			sb.WriteString(valueFn(edits[i].text))
+			i++
+		case opDelete:
+			var run strings.Builder
+			for ; i < len(edits) && edits[i].op == opDelete; i++ {
+				run.WriteString(edits[i].text)
+			}
+			sb.WriteString(diffFn("[-" + run.String() + "-]"))
+		case opInsert:
+			var run strings.Builder
+			for ; i < len(edits) && edits[i].op == opInsert; i++ {
+				run.WriteString(edits[i].text)
+			}
+			sb.WriteString(matchFn("{+" + run.String() + "+}"))
+		}
+	}
+	return sb.String()
+}
+
+// tokenizeWords splits a line into words, runs of whitespace and single punctuation characters
+func tokenizeWords(s string) []string {
+	var tokens []string
+	class := func(r rune) int {
+		switch {
+		case unicode.IsSpace(r):
+			return 0
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	start := 0
+	runes := []rune(s)
+	for i := 1; i <= len(runes); i++ {
+		if i == len(runes) || class(runes[i]) != class(runes[start]) || class(runes[start]) == 2 {
+			tokens = append(tokens, string(runes[start:i]))
+			start = i
+		}
+	}
+	return tokens
+}
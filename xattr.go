@@ -0,0 +1,53 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/sys/unix"
+)
+
+// readXattrs returns the extended attributes of a file without following symlinks.
+// Values that are not valid UTF-8 are base64 encoded with a "base64:" prefix.
+func readXattrs(path string) map[string]string {
+	size, err := unix.Llistxattr(path, nil)
+	if err != nil || size <= 0 {
+		return nil
+	}
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(path, buf)
+	if err != nil {
+		return nil
+	}
+
+	attrs := make(map[string]string)
+	for _, name := range strings.Split(string(buf[:size]), "\x00") {
+		if name == "" {
+			continue
+		}
+		n, err := unix.Lgetxattr(path, name, nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, n)
+		if n > 0 {
+			if n, err = unix.Lgetxattr(path, name, value); err != nil {
+				continue
+			}
+			value = value[:n]
+		}
+		if utf8.Valid(value) {
+			attrs[name] = string(value)
+		} else {
+			attrs[name] = "base64:" + base64.StdEncoding.EncodeToString(value)
+		}
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+	return attrs
+}